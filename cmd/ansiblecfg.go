@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Values for GlobalConfigForce.AnsibleCfgPolicy
+const (
+	AnsibleCfgAllow = "allow" // default, any ansible.cfg can be used
+	AnsibleCfgWarn  = "warn"  // log a warning when ansible.cfg is overridden
+	AnsibleCfgDeny  = "deny"  // refuse to run when ansible.cfg is overridden
+)
+
+var (
+	ansibleCfgGlobalPath string = "/etc/ansible/ansible.cfg"
+)
+
+// Global force settings or empty settings if there is no global config file.
+func readGlobalForce() (GlobalConfigForce, error) {
+	if ok, _ := pathExists(ansibleTuiGlobalConfigPath, false); !ok {
+		return GlobalConfigForce{}, nil
+	}
+	d, err := ReadGlobalConfig()
+	if err != nil {
+		return GlobalConfigForce{}, err
+	}
+	return d.Force, nil
+}
+
+// Forced ANSIBLE_REMOTE_USER and ANSIBLE_PRIVATE_KEY_FILE override remote-user and ssh-private-key-file.
+// This is only applied to the copy of the config a command is run with, so forced values are never saved.
+// Other forced environment variables are set by commandEnv.
+func (c *PlaybookConfig) applyForcedParams() {
+
+	force, err := readGlobalForce()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Could not read global config for forced environment variables: %s", err))
+		return
+	}
+
+	for k, v := range force.EnvironmentVariables {
+		switch k {
+		case "ANSIBLE_REMOTE_USER":
+			c.RemoteUser = v
+		case "ANSIBLE_PRIVATE_KEY_FILE":
+			c.SshPrivateKeyFile = v
+		}
+	}
+}
+
+// Copy of the config with the forced remote user and SSH key applied, so they only apply to this run
+func (c *PlaybookConfig) forcedRun() *PlaybookConfig {
+	run := *c
+	run.applyForcedParams()
+	return &run
+}
+
+// AnsibleConfigFile returns the ansible.cfg that ansible-playbook will use and why, following the
+// ansible precedence: ANSIBLE_CONFIG (if it is set or passed to ansible), ./ansible.cfg, ~/.ansible.cfg, /etc/ansible/ansible.cfg.
+// For container runs, ~/.ansible.cfg and /etc/ansible/ansible.cfg on the host are not used (the image's
+// /etc/ansible/ansible.cfg applies instead).
+func (c *PlaybookConfig) AnsibleConfigFile() (string, string) {
+
+	if p := c.commandEnv()["ANSIBLE_CONFIG"]; p != "" {
+		return p, "ANSIBLE_CONFIG"
+	}
+	if ok, _ := pathExists("ansible.cfg", false); ok {
+		return "./ansible.cfg", "current directory"
+	}
+	if c.Image != "" {
+		return ansibleCfgGlobalPath, "image"
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		p := filepath.Join(home, ".ansible.cfg")
+		if ok, _ := pathExists(p, false); ok {
+			return p, "home directory"
+		}
+	}
+	if ok, _ := pathExists(ansibleCfgGlobalPath, false); ok {
+		return ansibleCfgGlobalPath, "default"
+	}
+	return "", "none"
+}
+
+// Apply the global ansible.cfg policy.  ANSIBLE_CONFIG, ./ansible.cfg and ~/.ansible.cfg all take
+// precedence over /etc/ansible/ansible.cfg unless ANSIBLE_CONFIG is forced by the global config.
+func (c *PlaybookConfig) checkAnsibleCfgPolicy(force GlobalConfigForce) error {
+
+	policy := force.AnsibleCfgPolicy
+	if policy == "" || policy == AnsibleCfgAllow {
+		return nil
+	}
+	if policy != AnsibleCfgWarn && policy != AnsibleCfgDeny {
+		return &InputError{
+			Err: fmt.Errorf("ansible-cfg-policy must be allow, warn or deny: %s", policy),
+		}
+	}
+
+	if _, ok := force.EnvironmentVariables["ANSIBLE_CONFIG"]; ok {
+		return nil
+	}
+
+	path, source := c.AnsibleConfigFile()
+	if source == "default" || source == "none" || source == "image" {
+		return nil
+	}
+
+	msg := fmt.Sprintf("ansible.cfg from %s overrides %s: %s", source, ansibleCfgGlobalPath, path)
+	if policy == AnsibleCfgDeny {
+		return &InputError{
+			Err: fmt.Errorf("%s (not allowed by global config)", msg),
+		}
+	}
+	slog.Warn(msg)
+	return nil
+}
+
+// Summary of the playbook run printed before ansible-playbook starts.
+func (c *PlaybookConfig) runSummary(ansiblePlaybookPath string) string {
+
+	cfgPath, cfgSource := c.AnsibleConfigFile()
+	if cfgPath == "" {
+		cfgPath = "none (ansible defaults)"
+	} else if cfgSource == "image" {
+		cfgPath = fmt.Sprintf("%s in %s, if it exists", cfgPath, c.Image)
+	} else {
+		cfgPath = fmt.Sprintf("%s (%s)", cfgPath, cfgSource)
+	}
+
+	lines := []string{fmt.Sprintf("ansible-playbook: %s", ansiblePlaybookPath)}
+	if c.Image != "" {
+		lines = append(lines, c.imageSummary())
+	}
+	lines = append(lines,
+		fmt.Sprintf("playbook:         %s", c.Playbook),
+		fmt.Sprintf("inventory:        %s", c.InventoryFile),
+	)
+	if c.LimitHost != "" {
+		lines = append(lines, fmt.Sprintf("limit:            %s", c.LimitHost))
+	}
+	if c.RemoteUser != "" {
+		lines = append(lines, fmt.Sprintf("remote user:      %s", c.RemoteUser))
+	}
+	if c.SshPrivateKeyFile != "" {
+		lines = append(lines, fmt.Sprintf("ssh key:          %s", c.SshPrivateKeyFile))
+	}
+	lines = append(lines, fmt.Sprintf("ansible.cfg:      %s", cfgPath))
+
+	return strings.Join(lines, "\n")
+}
+
+// Image line of the run summary with the digest that the image resolved to (see PullImage)
+func (c *PlaybookConfig) imageSummary() string {
+	digest := c.Metrics.ImageDigest
+	if digest == "" {
+		digest = "digest unknown"
+	}
+	return fmt.Sprintf("image:            %s (%s)", c.Image, digest)
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	inventoryCacheDirName string = "inventory-cache"
+
+	// cache entries that were not written for this long are removed
+	inventoryCacheMaxAge = 7 * 24 * time.Hour
+)
+
+// Entry written to the inventory cache directory for each parsed inventory.
+type inventoryCacheEntry struct {
+	InventoryFile string   `json:"inventory"`
+	Lines         []string `json:"lines"`
+}
+
+func (c *PlaybookConfig) inventoryCacheDir() string {
+	return filepath.Join(c.TempDirPath, inventoryCacheDirName)
+}
+
+// Files that affect the output of ansible-inventory for invFilePath.  This includes the inventory
+// file (or every file in an inventory directory) and any group_vars or host_vars next to it.
+func inventoryCacheFiles(invFilePath string) ([]string, error) {
+	var files []string
+
+	stat, err := os.Stat(invFilePath)
+	if err != nil {
+		return files, err
+	}
+
+	roots := []string{}
+	invDir := filepath.Dir(invFilePath)
+	if stat.IsDir() {
+		roots = append(roots, invFilePath)
+		invDir = invFilePath
+	} else {
+		files = append(files, invFilePath)
+	}
+	for _, d := range []string{"group_vars", "host_vars"} {
+		p := filepath.Join(invDir, d)
+		if ok, _ := pathExists(p, true); ok {
+			roots = append(roots, p)
+		}
+	}
+
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			files = append(files, path)
+			return nil
+		})
+		if err != nil {
+			return files, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Key for the inventory cache based on the content of the inventory files, the runtime used to parse
+// them (image or virtual environment), the ansible.cfg and the ANSIBLE_* variables of the command environment
+// (ex. ANSIBLE_INVENTORY_ENABLED, ANSIBLE_VAULT_IDENTITY_LIST).  Dynamic inventories (plugins and scripts)
+// are not cached because their results do not depend on file content alone.
+func (c *PlaybookConfig) inventoryCacheKey(invFilePath string) (string, error) {
+
+	files, err := inventoryCacheFiles(invFilePath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range files {
+		if info, _ := DetectInventory(file); info.IsDynamic() {
+			return "", fmt.Errorf("dynamic inventory is not cached: %s (%s)", file, info.Label())
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "inventory=%s\nimage=%s\nvenv=%s\n", invFilePath, c.Image, c.VirtualEnvPath)
+	for _, e := range envList(c.commandEnv()) {
+		if strings.HasPrefix(e, "ANSIBLE_") {
+			fmt.Fprintf(h, "env=%s\n", e)
+		}
+	}
+
+	// the ansible.cfg of an image can't be read on the host, the image is part of the key instead
+	if cfgPath, cfgSource := c.AnsibleConfigFile(); cfgPath != "" && cfgSource != "image" {
+		fmt.Fprintf(h, "ansible.cfg=%s\n", cfgPath)
+		if b, err := os.ReadFile(cfgPath); err == nil {
+			h.Write(b)
+		}
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "file=%s\n", file)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Returns cached ansible-inventory --graph output for invFilePath or nil if there is no valid cache entry.
+func (c *PlaybookConfig) readInventoryCache(invFilePath string) *[]string {
+
+	key, err := c.inventoryCacheKey(invFilePath)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("Could not compute inventory cache key for %s: %s", invFilePath, err))
+		return nil
+	}
+
+	b, err := os.ReadFile(filepath.Join(c.inventoryCacheDir(), key+".json"))
+	if err != nil {
+		slog.Debug(fmt.Sprintf("No inventory cache entry for %s", invFilePath))
+		return nil
+	}
+
+	entry := inventoryCacheEntry{}
+	err = json.Unmarshal(b, &entry)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Ignoring invalid inventory cache entry for %s: %s", invFilePath, err))
+		return nil
+	}
+
+	slog.Info(fmt.Sprintf("Using cached inventory for %s", invFilePath))
+	return &entry.Lines
+}
+
+func (c *PlaybookConfig) writeInventoryCache(invFilePath string, lines []string) error {
+
+	key, err := c.inventoryCacheKey(invFilePath)
+	if err != nil {
+		return err
+	}
+
+	err = ensureDir(c.inventoryCacheDir())
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(&inventoryCacheEntry{InventoryFile: invFilePath, Lines: lines})
+	if err != nil {
+		return err
+	}
+
+	cacheFile := filepath.Join(c.inventoryCacheDir(), key+".json")
+	slog.Debug(fmt.Sprintf("Writing inventory cache for %s: %s", invFilePath, cacheFile))
+	err = os.WriteFile(cacheFile, b, 0600)
+	if err != nil {
+		return err
+	}
+
+	c.pruneInventoryCache(invFilePath, key)
+	return nil
+}
+
+// Remove older entries for invFilePath (only the entry with key is kept) and entries of any
+// inventory that are older than inventoryCacheMaxAge.
+func (c *PlaybookConfig) pruneInventoryCache(invFilePath string, key string) {
+
+	entries, err := os.ReadDir(c.inventoryCacheDir())
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") || name == key+".json" {
+			continue
+		}
+		path := filepath.Join(c.inventoryCacheDir(), name)
+
+		remove := false
+		if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > inventoryCacheMaxAge {
+			remove = true
+		} else if b, err := os.ReadFile(path); err == nil {
+			entry := inventoryCacheEntry{}
+			remove = json.Unmarshal(b, &entry) != nil || entry.InventoryFile == invFilePath
+		}
+
+		if remove {
+			slog.Debug(fmt.Sprintf("Removing inventory cache entry: %s", path))
+			os.Remove(path)
+		}
+	}
+}
+
+// ClearInventoryCache removes all cached inventory results from the temp directory.
+func (c *PlaybookConfig) ClearInventoryCache() error {
+	slog.Info(fmt.Sprintf("Clearing inventory cache: %s", c.inventoryCacheDir()))
+	return os.RemoveAll(c.inventoryCacheDir())
+}
@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// working directory in the container (the current directory is mounted here)
+	containerWorkDir string = "/app"
+
+	// ansible-tui in images built with the included Dockerfile
+	ansibleTuiContainerPath string = "/bin/ansible-tui"
+
+	// path of the generated config in the container, internal config keys are only accepted in this file
+	containerConfigEnvVar string = "ANSIBLE_TUI_CONTAINER_CONFIG"
+
+	// limit for checking an image for ansible-tui, so a hanging image doesn't block the TUI
+	containerProbeTimeout = 20 * time.Second
+
+	// images checked for ansible-tui by containerHasAnsibleTui
+	imageHasAnsibleTui   = make(map[string]bool)
+	imageHasAnsibleTuiMu sync.Mutex
+
+	regExpContainerOpt = regexp.MustCompile(`^[a-zA-Z0-9.\-_:=,/]+$`)
+	regExpCpus         = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+	regExpMemory       = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+)
+
+// Values of container.mode
+const (
+	ContainerModeAuto       = "auto"        // default, ansible-tui if the image contains it
+	ContainerModeAnsibleTui = "ansible-tui" // run ansible-tui in the container with the config
+	ContainerModeDirect     = "direct"      // run ansible-playbook, ansible-lint and ansible-inventory directly
+)
+
+// Values of container.user
+const (
+	ContainerUserKeepId = "keep-id" // podman --userns=keep-id
+	ContainerUserHost   = "host"    // uid:gid of the user running ansible-tui
+)
+
+// Settings for running ansible in a container (see executeCommandInContainer)
+type ContainerConfig struct {
+	Runtime      string             `yaml:"runtime,omitempty" json:"runtime,omitempty"`             // runtime binary (name or path), default podman then docker
+	Mode         string             `yaml:"mode,omitempty" json:"mode,omitempty"`                   // auto, ansible-tui or direct
+	User         string             `yaml:"user,omitempty" json:"user,omitempty"`                   // keep-id, host, user or uid:gid (default root)
+	MountOptions string             `yaml:"mount-options,omitempty" json:"mount-options,omitempty"` // options added to ro/rw of bind mounts (default z for podman, none for docker)
+	Network      string             `yaml:"network,omitempty" json:"network,omitempty"`             // network mode (ex. host)
+	RunArgs      []string           `yaml:"run-args,omitempty" json:"run-args,omitempty"`           // extra arguments for <runtime> run
+	Mounts       []ContainerMount   `yaml:"mounts,omitempty" json:"mounts,omitempty"`
+	Resources    ContainerResources `yaml:"resources,omitempty" json:"resources,omitempty"`
+}
+
+// Additional bind mount (ex. ~/.ssh/known_hosts or a collections cache)
+type ContainerMount struct {
+	Source  string `yaml:"source" json:"source"`                       // host path, must exist
+	Target  string `yaml:"target" json:"target"`                       // absolute path in the container
+	Mode    string `yaml:"mode,omitempty" json:"mode,omitempty"`       // ro (default) or rw
+	Options string `yaml:"options,omitempty" json:"options,omitempty"` // options added to the mode (ex. z), none by default
+}
+
+// Resource limits of the container
+type ContainerResources struct {
+	Cpus      string `yaml:"cpus,omitempty" json:"cpus,omitempty"`             // number of CPUs (ex. 1.5)
+	Memory    string `yaml:"memory,omitempty" json:"memory,omitempty"`         // memory limit (ex. 512m, 2g)
+	PidsLimit int    `yaml:"pids-limit,omitempty" json:"pids-limit,omitempty"` // maximum number of processes
+}
+
+// Defaults for a container runtime
+type containerDefaults struct {
+	User         string
+	MountOptions string
+}
+
+var (
+	runtimeDefaults = map[string]containerDefaults{
+		"podman": {User: "root", MountOptions: "z"},
+		"docker": {User: "root", MountOptions: ""},
+	}
+)
+
+// Defaults of the runtime by the name of its binary (docker defaults for other runtimes)
+func defaultsForRuntime(runtime string) containerDefaults {
+	if d, ok := runtimeDefaults[filepath.Base(runtime)]; ok {
+		return d
+	}
+	return runtimeDefaults["docker"]
+}
+
+func isPodman(runtime string) bool {
+	return filepath.Base(runtime) == "podman"
+}
+
+// Mount option for a bind mount with mode ro or rw.  container.mount-options "none" disables the default options.
+func (c *PlaybookConfig) mountOptions(runtime string, mode string) string {
+	opts := c.Container.MountOptions
+	if opts == "" {
+		opts = defaultsForRuntime(runtime).MountOptions
+	}
+	if opts == "" || opts == "none" {
+		return mode
+	}
+	return mode + "," + opts
+}
+
+// Arguments for <runtime> run from the container settings (user mapping, network and run-args)
+func (c *PlaybookConfig) containerRunArgs(runtime string) []string {
+	var args []string
+
+	user := c.Container.User
+	if user == "" {
+		user = defaultsForRuntime(runtime).User
+	}
+	switch user {
+	case ContainerUserKeepId:
+		args = append(args, "--userns=keep-id")
+	case ContainerUserHost:
+		args = append(args, "-u", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	default:
+		args = append(args, "-u", user)
+	}
+
+	if c.Container.Network != "" {
+		args = append(args, "--network="+c.Container.Network)
+	}
+
+	for _, m := range c.Container.Mounts {
+		mode := m.Mode
+		if mode == "" {
+			mode = "ro"
+		}
+		if m.Options != "" {
+			mode += "," + m.Options
+		}
+		args = append(args, "-v", expandHome(m.Source)+":"+m.Target+":"+mode)
+	}
+
+	r := c.Container.Resources
+	if r.Cpus != "" {
+		args = append(args, "--cpus="+r.Cpus)
+	}
+	if r.Memory != "" {
+		args = append(args, "--memory="+r.Memory)
+	}
+	if r.PidsLimit > 0 {
+		args = append(args, fmt.Sprintf("--pids-limit=%d", r.PidsLimit))
+	}
+
+	return append(args, c.Container.RunArgs...)
+}
+
+// Container paths that are mounted by ansible-tui itself
+var reservedTargets = []string{"/app", containerRunDir}
+
+func validateMount(idx int, m ContainerMount) error {
+	key := fmt.Sprintf("container.mounts[%d]", idx)
+	fail := func(format string, a ...any) error {
+		return &InputError{
+			Err: fmt.Errorf(key+": "+format, a...),
+		}
+	}
+
+	if m.Source == "" || m.Target == "" {
+		return fail("source and target are required")
+	}
+	for _, p := range []string{m.Source, m.Target} {
+		if !regExpPolicyPathName.MatchString(p) || strings.ContainsAny(p, ":,") {
+			return fail("path contains invalid characters: %s", p)
+		}
+	}
+	if !filepath.IsAbs(m.Target) {
+		return fail("target must be an absolute path: %s", m.Target)
+	}
+	for _, r := range reservedTargets {
+		if pathUnder(filepath.Clean(m.Target), []string{r}) {
+			return fail("target is mounted by ansible-tui: %s", m.Target)
+		}
+	}
+	if m.Mode != "" && m.Mode != "ro" && m.Mode != "rw" {
+		return fail("mode must be ro or rw: %s", m.Mode)
+	}
+	if m.Options != "" && !regExpContainerOpt.MatchString(m.Options) {
+		return fail("options contain invalid characters: %s", m.Options)
+	}
+
+	source := expandHome(m.Source)
+	if !filepath.IsAbs(source) {
+		return fail("source must be an absolute path: %s", m.Source)
+	}
+	if _, err := os.Stat(source); err != nil {
+		return fail("source does not exist: %s", m.Source)
+	}
+	return nil
+}
+
+// Validate the container settings against the runtime that will be used (if it was found)
+func (c *PlaybookConfig) validateContainer(runtime string) error {
+
+	fail := func(format string, a ...any) error {
+		return &InputError{
+			Err: fmt.Errorf(format, a...),
+		}
+	}
+
+	if c.Container.User == ContainerUserKeepId && runtime != "" && !isPodman(runtime) {
+		return fail("container.user keep-id is only supported by podman: %s", runtime)
+	}
+	for key, value := range map[string]string{
+		"container.user":          c.Container.User,
+		"container.mount-options": c.Container.MountOptions,
+		"container.network":       c.Container.Network,
+	} {
+		if value != "" && !regExpContainerOpt.MatchString(value) {
+			return fail("%s contains invalid characters: %s", key, value)
+		}
+	}
+	switch c.Container.Mode {
+	case "", ContainerModeAuto, ContainerModeAnsibleTui, ContainerModeDirect:
+	default:
+		return fail("container.mode must be auto, ansible-tui or direct: %s", c.Container.Mode)
+	}
+
+	for i, m := range c.Container.Mounts {
+		if err := validateMount(i, m); err != nil {
+			return err
+		}
+	}
+
+	r := c.Container.Resources
+	if r.Cpus != "" && !regExpCpus.MatchString(r.Cpus) {
+		return fail("container.resources.cpus must be a number: %s", r.Cpus)
+	}
+	if r.Memory != "" && !regExpMemory.MatchString(r.Memory) {
+		return fail("container.resources.memory must be a number with an optional unit (b, k, m, g): %s", r.Memory)
+	}
+	if r.PidsLimit < 0 {
+		return fail("container.resources.pids-limit must not be negative: %d", r.PidsLimit)
+	}
+
+	for _, arg := range c.Container.RunArgs {
+		if strings.TrimSpace(arg) == "" {
+			return fail("container.run-args contains an empty argument")
+		}
+	}
+	return nil
+}
+
+// True if ansible-tui is run in the container (see container.mode).  With auto, the image is checked for
+// ansible-tui once per image digest.  Images without it (ex. ansible-builder execution environments) run
+// ansible-playbook and ansible-lint directly.
+func (c *PlaybookConfig) containerHasAnsibleTui() bool {
+
+	switch c.Container.Mode {
+	case ContainerModeAnsibleTui:
+		return true
+	case ContainerModeDirect:
+		return false
+	}
+
+	key := c.Image + "@" + c.Metrics.ImageDigest
+	imageHasAnsibleTuiMu.Lock()
+	defer imageHasAnsibleTuiMu.Unlock()
+	if found, ok := imageHasAnsibleTui[key]; ok {
+		return found
+	}
+
+	found := false
+	runtime, err := GetContainerRuntime(c.Container.Runtime)
+	if err == nil {
+		// test -x works for every version of ansible-tui (version is a subcommand only in newer versions)
+		ctx, cancel := context.WithTimeout(context.Background(), containerProbeTimeout)
+		defer cancel()
+		args := []string{"run", "--rm", "--pull=never"}
+		args = append(args, c.containerRunArgs(runtime)...)
+		args = append(args, "--entrypoint", "test", c.Image, "-x", ansibleTuiContainerPath)
+		checkCmd := exec.CommandContext(ctx, runtime, args...)
+		slog.Debug(checkCmd.String())
+		err = checkCmd.Run()
+		if ctx.Err() != nil {
+			slog.Warn(fmt.Sprintf("Checking image %s for ansible-tui timed out after %s", c.Image, containerProbeTimeout))
+		}
+		found = err == nil
+	}
+	slog.Info(fmt.Sprintf("image %s contains ansible-tui: %t", c.Image, found))
+	imageHasAnsibleTui[key] = found
+	return found
+}
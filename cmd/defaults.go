@@ -4,58 +4,65 @@ package cmd
 //       Also some of the helpers like IsPlaybookFile.
 
 import (
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
 )
 
-// Settings in this struct set initial default values for new PlaybookConfig structs.
-type GlobalConfigDefault struct {
-	RemoteUser string `yaml:"remote-user" json:"remote-user"`
-	Image      string `yaml:"image" json:"image"`
-}
-
 // This configuration file is read in from /etc/ansible/ansible-tui-force-config.yml.
 // Settings in this struct can be globally enforced (not available for user input).
 // This can be used for governance such as lint rules or logging.
 type GlobalConfigForce struct {
 	AnsibleLintFilePath string `yaml:"ansible-lint-file-path" json:"ansible-lint-file-path"`
 	AnsibleLintFileUrl  string `yaml:"ansible-lint-file-url" json:"ansible-lint-file-url"`
+	// sha256 of the ansible-lint file ("sha256:<hex>" or "<hex>")
+	AnsibleLintFileChecksum  string            `yaml:"ansible-lint-file-checksum" json:"ansible-lint-file-checksum"`
+	AnsibleLintPolicyVersion string            `yaml:"ansible-lint-policy-version" json:"ansible-lint-policy-version"`
+	AnsibleCfgPolicy         string            `yaml:"ansible-cfg-policy" json:"ansible-cfg-policy"`
+	EnvironmentVariables     map[string]string `yaml:"environment-variables" json:"environment-variables"`
+	// names or patterns (ex. AWS_*) of environment variables that are always or never passed
+	AlwaysPassEnvs []string        `yaml:"always-pass-environment-variables" json:"always-pass-environment-variables"`
+	NeverPassEnvs  []string        `yaml:"never-pass-environment-variables" json:"never-pass-environment-variables"`
+	PathPolicy     PathPolicyForce `yaml:"path-policy" json:"path-policy"`
 }
 
 // This configuration file is read in from /etc/ansible/ansible-tui-config.yml.
+// Defaults can set any PlaybookConfig key (same format as the project config file).
+// They are decoded onto PlaybookConfig before any other layer, so only keys that are
+// present override the internal defaults.
 type GlobalConfig struct {
-	Defaults GlobalConfigDefault `yaml:"default" json:"default"`
-	Force    GlobalConfigForce   `yaml:"force" json:"force"`
+	Defaults yaml.Node         `yaml:"default" json:"-"`
+	Force    GlobalConfigForce `yaml:"force" json:"force"`
 }
 
 var (
 	ansibleTuiGlobalConfigPath string = "/etc/ansible/ansible-tui-config.yml"
 )
 
-func (c *GlobalConfigDefault) toMAP() (res map[string]interface{}) {
-	a, err := json.Marshal(c)
-	if err != nil {
-		panic(err)
+// Recursive scan options that honor tui.include and tui.exclude
+func (c *PlaybookConfig) scanOptions(exts []string) ScanOptions {
+	return ScanOptions{
+		Exts:    exts,
+		Recurse: true,
+		Include: c.Tui.Include,
+		Exclude: c.Tui.Exclude,
 	}
-	json.Unmarshal(a, &res)
-	return
 }
 
-func (c *PlaybookConfig) toMAP() (res map[string]interface{}) {
-	a, err := json.Marshal(c)
-	if err != nil {
-		panic(err)
+// Directory of a file returned by Scan, formatted like the other tui directories (ex. "./playbooks")
+func scanFileDir(path string) string {
+	dir := filepath.Dir(path)
+	if dir == "." || filepath.IsAbs(dir) {
+		return dir
 	}
-	json.Unmarshal(a, &res)
-	return
+	return "./" + dir
 }
 
-func findPlaybookDir() string {
+func (c *PlaybookConfig) findPlaybookDir() string {
 	if ok, _ := pathExists("./playbooks", true); ok {
 		return "./playbooks"
 	}
@@ -69,7 +76,7 @@ func findPlaybookDir() string {
 		slog.Error(fmt.Sprintf("%s", err))
 	} else {
 		for _, line := range yamlFiles {
-			if line == "site.yml" || line == "site.yaml" {
+			if name := filepath.Base(line); name == "site.yml" || name == "site.yaml" {
 				return "."
 			}
 			pbBool, err := IsPlaybookFile(line)
@@ -85,14 +92,14 @@ func findPlaybookDir() string {
 
 	// Recurse into directories looking for playbooks
 	// TODO: Should skip files already inspected above, but it wouldn't save much time.
-	yamlFiles, err = WalkDir(".", fileExt)
+	yamlFiles, err = NewScanner().Scan(".", c.scanOptions(fileExt))
 	if err != nil {
 		slog.Error(fmt.Sprintf("%s", err))
 	} else {
 		for _, line := range yamlFiles {
-			if line == "site.yml" || line == "site.yaml" {
+			if name := filepath.Base(line); name == "site.yml" || name == "site.yaml" {
 				slog.Debug(fmt.Sprintf("Found playbook directory based on named file: %s", line))
-				return "./" + filepath.Dir(line)
+				return scanFileDir(line)
 			}
 			pbBool, err := IsPlaybookFile(line)
 			if err != nil {
@@ -100,7 +107,7 @@ func findPlaybookDir() string {
 			}
 			if pbBool {
 				slog.Debug(fmt.Sprintf("Found playbook directory based on file: %s", line))
-				return "./" + filepath.Dir(line)
+				return scanFileDir(line)
 			}
 		}
 	}
@@ -109,7 +116,7 @@ func findPlaybookDir() string {
 	return "."
 }
 
-func findInventoryDir() string {
+func (c *PlaybookConfig) findInventoryDir() string {
 	if ok, _ := pathExists("./inventory", true); ok {
 		slog.Debug("Found inventory directory: ./inventory")
 		return "./inventory"
@@ -122,7 +129,7 @@ func findInventoryDir() string {
 		slog.Error(fmt.Sprintf("%s", err))
 	} else {
 		for _, line := range yamlFiles {
-			pbBool, err := IsPlaybookFile(line)
+			pbBool, err := IsInventory(line)
 			if err != nil {
 				slog.Debug(fmt.Sprintf("Could not check if file is inventory: %s, %s", line, err))
 			}
@@ -135,7 +142,7 @@ func findInventoryDir() string {
 
 	// Recurse into directories looking for inventory.
 	// TODO: Should skip files already inspected above, but it wouldn't save much time.
-	yamlFiles, err = WalkDir(".", fileExt)
+	yamlFiles, err = NewScanner().Scan(".", c.scanOptions(fileExt))
 	if err != nil {
 		slog.Error(fmt.Sprintf("%s", err))
 	} else {
@@ -147,7 +154,7 @@ func findInventoryDir() string {
 			}
 			if pbBool {
 				slog.Debug(fmt.Sprintf("Found inventory directory based on file: %s", line))
-				return "./" + filepath.Dir(line)
+				return scanFileDir(line)
 			}
 		}
 	}
@@ -158,50 +165,55 @@ func findInventoryDir() string {
 
 func (c *PlaybookConfig) GenerateTemplateFile(defaultConfigFilePath string) error {
 
+	// global defaults have the lowest precedence, followed by environment variables
+	err := c.readGlobalDefaults()
+	if err != nil {
+		return err
+	}
+
 	// read environment variables into PlaybookConfig struct (also creates temp-dir)
-	err := c.ReadEnvs()
+	err = c.ReadEnvs()
 	if err != nil {
 		slog.Error(fmt.Sprintf("Exiting due to error reading environment variables: %s", err))
-		os.Exit(1)
+		return err
 	}
 
-	playbookDir := findPlaybookDir()
+	// TUI_* environment variables take precedence over searching the current directory
+	playbookDir := c.Tui.PlaybookDir
+	if playbookDir == "" {
+		playbookDir = c.findPlaybookDir()
+	}
 
-	inventoryDir := findInventoryDir()
+	inventoryDir := c.Tui.InventoryDir
+	if inventoryDir == "" {
+		inventoryDir = c.findInventoryDir()
+	}
 
-	// If ansibleTuiGlobalConfigPath exists, read it in and merge with new values
-	// TODO: Make this cleaner.  Merging maps is easy, but not structs.
-	if ok, _ := pathExists(ansibleTuiGlobalConfigPath, false); ok {
-		d, err := ReadGlobalConfig()
-		if err == nil {
-			// unmarshal global defaults into map
-			dMap := d.Defaults.toMAP()
-			// unmarshal current config into map
-			cMap := c.toMAP()
-			// merge maps (overwriting values in Playbook/cMap)
-			for k, v := range dMap {
-				cMap[k] = v
-			}
-			// Marshal merged map back into PlaybookConfig struct
-			cTemp, _ := json.Marshal(cMap)
-			_ = json.Unmarshal(cTemp, &c)
-		}
+	imageFilter := "ansible"
+	if c.Source("tui.image-filter") != SourceDefault {
+		imageFilter = c.Tui.ImageFilter
+	}
+
+	// ssh-agent auto only uses the agent when no key file is set, so the default key is commented out
+	sshPrivateKeyFile := `# ssh-private-key-file: "~/.ssh/id_rsa"`
+	if c.SshPrivateKeyFile != "" {
+		sshPrivateKeyFile = `ssh-private-key-file: "` + c.SshPrivateKeyFile + `"`
 	}
 
 	contents := `---
 # virtual-env-path: ""
 image: "` + c.Image + `"
-ssh-private-key-file: "~/.ssh/id_rsa"
+` + sshPrivateKeyFile + `
 remote-user: "` + c.RemoteUser + `"
-inventory: ""
-playbook: ""
-verbose-level: 0
+inventory: "` + c.InventoryFile + `"
+playbook: "` + c.Playbook + `"
+verbose-level: ` + strconv.Itoa(c.VerboseLevel) + `
 
 tui:
   playbook-dir: "` + playbookDir + `"
   inventory-dir: "` + inventoryDir + `"
-  image-filter: ansible
-  virtual-envs-dir: ""
+  image-filter: "` + imageFilter + `"
+  virtual-envs-dir: "` + c.Tui.VirtualEnvsDir + `"
 `
 
 	WriteFileFromString(defaultConfigFilePath, contents, 0640)
@@ -221,6 +233,12 @@ func ReadGlobalConfig() (*GlobalConfig, error) {
 		return nil, err
 	}
 
+	err = validateConfig(ansibleTuiGlobalConfigPath, buf, globalConfigType)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Invalid global config file: %s", ansibleTuiGlobalConfigPath))
+		return nil, err
+	}
+
 	c := &GlobalConfig{}
 	err = yaml.Unmarshal(buf, c)
 	if err != nil {
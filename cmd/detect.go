@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type InventoryType string
+
+const (
+	InventoryTypeNone   InventoryType = ""
+	InventoryTypeYaml   InventoryType = "yaml"
+	InventoryTypeIni    InventoryType = "ini"
+	InventoryTypePlugin InventoryType = "plugin"
+	InventoryTypeScript InventoryType = "script"
+)
+
+// Result of inspecting a file for inventory content.
+// Plugin is only set for inventory plugin configuration files (ex. amazon.aws.aws_ec2).
+type InventoryInfo struct {
+	Type   InventoryType
+	Plugin string
+}
+
+var (
+	// files larger than this are not read to detect inventory or playbooks
+	detectMaxFileSize int64 = 5 * 1024 * 1024
+
+	regExpIniSection = regexp.MustCompile(`^\[([^\[\]:\s]+)(:(vars|children))?\]$`)
+	regExpIniHost    = regexp.MustCompile(`^[\w.\-\[\]:@]+$`)
+	regExpIniVar     = regexp.MustCompile(`^[\w.\-]+=`)
+	regExpIniFqdn    = regexp.MustCompile(`^(localhost|[\w\-\[\]:]+(\.[\w\-\[\]:]+)+)(:\d+)?$`)
+
+	// keys allowed for a group in a YAML inventory
+	yamlInventoryGroupKeys = map[string]bool{"hosts": true, "children": true, "vars": true}
+)
+
+// Label for display purposes (ex. "yaml", "plugin: amazon.aws.aws_ec2").
+func (i InventoryInfo) Label() string {
+	if i.Type == InventoryTypePlugin && i.Plugin != "" {
+		return fmt.Sprintf("%s: %s", i.Type, i.Plugin)
+	}
+	return string(i.Type)
+}
+
+// Dynamic inventories (plugins and scripts) depend on external state, not just file content.
+func (i InventoryInfo) IsDynamic() bool {
+	return i.Type == InventoryTypePlugin || i.Type == InventoryTypeScript
+}
+
+func readDetectFile(file string) ([]byte, os.FileInfo, error) {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stat.IsDir() {
+		return nil, stat, &InputError{
+			Err: fmt.Errorf("path must be a file, not a directory: %s", file),
+		}
+	}
+	if stat.Size() > detectMaxFileSize {
+		return nil, stat, &InputError{
+			Err: fmt.Errorf("file is too large to inspect: %s", file),
+		}
+	}
+	b, err := os.ReadFile(file)
+	return b, stat, err
+}
+
+// DetectInventory determines whether file is an Ansible inventory and which kind it is.
+// Executable scripts, inventory plugin configurations, YAML and INI inventories are recognized.
+func DetectInventory(file string) (InventoryInfo, error) {
+
+	info := InventoryInfo{}
+
+	b, stat, err := readDetectFile(file)
+	if err != nil {
+		return info, err
+	}
+
+	// Inventory scripts must be executable and support --list
+	if stat.Mode()&0111 != 0 && bytes.HasPrefix(b, []byte("#!")) {
+		if bytes.Contains(b, []byte("--list")) {
+			info.Type = InventoryTypeScript
+		}
+		return info, nil
+	}
+
+	ext := filepath.Ext(file)
+	if ext == ".yml" || ext == ".yaml" || ext == ".json" {
+		return detectYamlInventory(b), nil
+	}
+
+	if isIniInventory(b, ext == ".ini") {
+		info.Type = InventoryTypeIni
+	}
+	return info, nil
+}
+
+// IsInventory returns true if file is any type of Ansible inventory.
+func IsInventory(file string) (bool, error) {
+	info, err := DetectInventory(file)
+	if err != nil {
+		return false, err
+	}
+	return info.Type != InventoryTypeNone, nil
+}
+
+func detectYamlInventory(b []byte) InventoryInfo {
+
+	info := InventoryInfo{}
+
+	// Inventories are mappings at the top level (playbooks and task files are lists)
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil || len(doc) == 0 {
+		return info
+	}
+
+	// Inventory plugin configurations (aws_ec2, constructed, generator, etc.) require a plugin key
+	if plugin, ok := doc["plugin"].(string); ok && plugin != "" {
+		info.Type = InventoryTypePlugin
+		info.Plugin = plugin
+		return info
+	}
+
+	// Every top-level key is a group.  Groups are empty or only contain hosts, children, and vars.
+	// At least one group must define hosts or children, which excludes vars files.
+	found := false
+	for _, v := range doc {
+		if v == nil {
+			continue
+		}
+		group, ok := v.(map[string]interface{})
+		if !ok {
+			return info
+		}
+		for k := range group {
+			if !yamlInventoryGroupKeys[k] {
+				return info
+			}
+			if k == "hosts" || k == "children" {
+				found = true
+			}
+		}
+	}
+
+	if found {
+		info.Type = InventoryTypeYaml
+	}
+	return info
+}
+
+func isIniInventory(b []byte, iniExt bool) bool {
+
+	// Every line must be a comment, a section header, a host (optionally with key=value vars),
+	// a child group name or a variable assignment in a :vars section.
+	// Plain lists of words (requirements.txt, password files) also match these rules, so at least
+	// one stronger signal is required: an .ini extension, a section, host vars or a FQDN/IP host.
+	hosts := 0
+	signal := iniExt
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if m := regExpIniSection.FindStringSubmatch(line); m != nil {
+			section = m[3]
+			signal = true
+			continue
+		}
+
+		if section == "vars" {
+			if !regExpIniVar.MatchString(line) {
+				return false
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if !regExpIniHost.MatchString(fields[0]) {
+			return false
+		}
+		for _, f := range fields[1:] {
+			if !regExpIniVar.MatchString(f) {
+				return false
+			}
+			signal = true
+		}
+		if regExpIniFqdn.MatchString(fields[0]) {
+			signal = true
+		}
+		hosts++
+	}
+	if scanner.Err() != nil {
+		return false
+	}
+
+	return hosts > 0 && signal
+}
+
+// Summary of a single play in a playbook.
+// Import is set instead of Hosts for import_playbook entries.
+type PlaySummary struct {
+	Name   string
+	Hosts  string
+	Import string
+}
+
+// Entry in the playbook index built by IndexPlaybooks.
+type PlaybookInfo struct {
+	Path  string
+	Plays []PlaySummary
+}
+
+// Short description of the plays for display purposes (ex. "Setup web servers (webservers)").
+func (p PlaybookInfo) Summary() string {
+	var plays []string
+	for _, play := range p.Plays {
+		if play.Import != "" {
+			plays = append(plays, fmt.Sprintf("import: %s", play.Import))
+			continue
+		}
+		name := play.Name
+		if name == "" {
+			name = "unnamed play"
+		}
+		plays = append(plays, fmt.Sprintf("%s (%s)", name, play.Hosts))
+	}
+	return strings.Join(plays, "; ")
+}
+
+// DetectPlaybook parses file as YAML and returns the plays if it is a playbook.
+// A playbook is a top-level list of plays where every play has hosts or import_playbook.
+// Task files, vars files and inventories return nil.
+func DetectPlaybook(file string) (*PlaybookInfo, error) {
+
+	b, _, err := readDetectFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc []map[string]interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil || len(doc) == 0 {
+		return nil, nil
+	}
+
+	info := PlaybookInfo{Path: file}
+	for _, play := range doc {
+		summary := PlaySummary{}
+		summary.Name, _ = play["name"].(string)
+
+		if imp, ok := playImport(play); ok {
+			summary.Import = imp
+			info.Plays = append(info.Plays, summary)
+			continue
+		}
+
+		hosts, ok := play["hosts"]
+		if !ok {
+			return nil, nil
+		}
+		switch h := hosts.(type) {
+		case string:
+			summary.Hosts = h
+		case []interface{}:
+			var hostList []string
+			for _, v := range h {
+				hostList = append(hostList, fmt.Sprintf("%v", v))
+			}
+			summary.Hosts = strings.Join(hostList, ",")
+		default:
+			return nil, nil
+		}
+		info.Plays = append(info.Plays, summary)
+	}
+
+	return &info, nil
+}
+
+func playImport(play map[string]interface{}) (string, bool) {
+	for _, k := range []string{"import_playbook", "ansible.builtin.import_playbook"} {
+		if v, ok := play[k]; ok {
+			return fmt.Sprintf("%v", v), true
+		}
+	}
+	return "", false
+}
+
+// IsPlaybookFile returns true if file is an Ansible playbook.
+func IsPlaybookFile(file string) (bool, error) {
+	info, err := DetectPlaybook(file)
+	if err != nil {
+		return false, err
+	}
+	return info != nil, nil
+}
+
+// IndexPlaybooks returns the playbooks found in files with their plays (files that are not playbooks are skipped).
+func IndexPlaybooks(files []string) []PlaybookInfo {
+	var index []PlaybookInfo
+	for _, file := range files {
+		info, err := DetectPlaybook(file)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("Could not check if file is playbook: %s, %s", file, err))
+			continue
+		}
+		if info != nil {
+			index = append(index, *info)
+		}
+	}
+	return index
+}
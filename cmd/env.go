@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Environment for commands run by ansible-tui (ansible-playbook, ansible-lint and ansible-inventory).
+// Only PATH, HOME, environment-variables.set and the variables passed through by environment-variables.pass
+// are included (see EnvPassList) and the bin directory of the virtualenv is prepended to PATH.
+// The environment of ansible-tui itself is never changed.
+func (c *PlaybookConfig) commandEnv() map[string]string {
+
+	force, err := readGlobalForce()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Could not read global config for command environment: %s", err))
+	}
+
+	env := make(map[string]string)
+	for _, e := range c.envPassList(force) {
+		switch e.Status {
+		case EnvSet:
+			if v, ok := force.EnvironmentVariables[e.Name]; ok {
+				env[e.Name] = v
+			} else {
+				env[e.Name] = c.EnvironmentVariables.Set[e.Name]
+			}
+		case EnvPassed:
+			env[e.Name] = os.Getenv(e.Name)
+		}
+	}
+
+	if _, ok := env["SSH_AUTH_SOCK"]; !ok && c.UsesSshAgent() {
+		env["SSH_AUTH_SOCK"] = c.sshAgentSock
+	}
+
+	if c.VirtualEnvPath != "" {
+		env["PATH"] = filepath.Join(c.VirtualEnvPath, "bin") + string(os.PathListSeparator) + env["PATH"]
+	}
+
+	return env
+}
+
+// Convert a command environment to the format of exec.Cmd.Env
+func envList(env map[string]string) []string {
+	l := make([]string, 0, len(env))
+	for k, v := range env {
+		l = append(l, k+"="+v)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// Find a command in the PATH of a command environment.  exec.Command only searches the PATH of
+// ansible-tui, so commands are run with the path returned here.
+func lookPath(name string, env map[string]string) (string, error) {
+	for _, dir := range filepath.SplitList(env["PATH"]) {
+		if dir == "" {
+			continue
+		}
+		p := filepath.Join(dir, name)
+		if stat, err := os.Stat(p); err == nil && !stat.IsDir() && stat.Mode()&0111 != 0 {
+			return p, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// Command environment for a container.  PATH and HOME of the container are kept, and the SSH agent
+// socket is set by executeCommandInContainer.
+func (c *PlaybookConfig) containerEnv() map[string]string {
+	env := c.commandEnv()
+	for _, k := range autoPassEnvs {
+		delete(env, k)
+	}
+	delete(env, "SSH_AUTH_SOCK")
+	return env
+}
+
+// Write a container environment to a file (0600) for <runtime> run --env-file.  Returns the path of the
+// file and the names of the variables in it.  The caller removes the file after the run.
+func writeContainerEnvFile(env map[string]string) (string, []string, error) {
+
+	var names []string
+	var contents strings.Builder
+	for _, e := range envList(env) {
+		k, v, _ := strings.Cut(e, "=")
+		if strings.ContainsAny(v, "\r\n") {
+			return "", nil, &InputError{
+				Err: fmt.Errorf("environment variable %s contains a newline, which can't be passed to a container", k),
+			}
+		}
+		names = append(names, k)
+		contents.WriteString(e + "\n")
+	}
+
+	// not in temp-dir, which is mounted into the container
+	f, err := os.CreateTemp("", "ansible-tui-env-")
+	if err != nil {
+		slog.Error("Could not create container env file")
+		return "", nil, err
+	}
+	defer f.Close()
+
+	// os.CreateTemp creates the file with mode 0600
+	_, err = f.WriteString(contents.String())
+	if err != nil {
+		os.Remove(f.Name())
+		return "", nil, err
+	}
+	return f.Name(), names, nil
+}
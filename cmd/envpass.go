@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Status of an environment variable in EnvPassList
+const (
+	EnvPassed = "pass"
+	EnvSet    = "set"
+	EnvDenied = "deny"
+)
+
+var (
+	// These env keys are required for a default shell and are always passed
+	autoPassEnvs = []string{"PATH", "HOME"}
+)
+
+// An environment variable and the rule that decides whether it is passed to ansible.
+type EnvPassEntry struct {
+	Name   string
+	Status string // EnvPassed, EnvSet or EnvDenied
+	Rule   string // pattern or key that matched
+	Source string // config layer of the rule
+}
+
+// Returns the first pattern in patterns that matches name.  Patterns are exact names or globs (ex. ANSIBLE_*).
+func matchEnvPattern(name string, patterns []string) (string, bool) {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// EnvPassList returns the environment variables that are set by the config, passed to ansible or
+// denied, with the rule that decided it.  Rules are checked in this order: PATH and HOME,
+// never-pass-environment-variables (global force), environment-variables.set,
+// always-pass-environment-variables (global force), environment-variables.deny and environment-variables.pass.
+// Variables forced by the global config are always set.
+func (c *PlaybookConfig) EnvPassList() ([]EnvPassEntry, error) {
+	force, err := readGlobalForce()
+	if err != nil {
+		return nil, err
+	}
+	return c.envPassList(force), nil
+}
+
+func (c *PlaybookConfig) envPassList(force GlobalConfigForce) []EnvPassEntry {
+
+	forceSource := fmt.Sprintf("%s (%s force)", SourceSystem, ansibleTuiGlobalConfigPath)
+	byName := make(map[string]EnvPassEntry)
+
+	for k := range c.EnvironmentVariables.Set {
+		entry := EnvPassEntry{Name: k, Status: EnvSet, Rule: "environment-variables.set", Source: c.Source("environment-variables.set." + k)}
+		if p, ok := matchEnvPattern(k, force.NeverPassEnvs); ok {
+			entry.Status, entry.Rule, entry.Source = EnvDenied, p, forceSource
+		}
+		byName[k] = entry
+	}
+	for k := range force.EnvironmentVariables {
+		byName[k] = EnvPassEntry{Name: k, Status: EnvSet, Rule: "environment-variables", Source: forceSource}
+	}
+
+	for _, e := range os.Environ() {
+		k, _, _ := strings.Cut(e, "=")
+		if _, ok := byName[k]; ok {
+			continue
+		}
+
+		entry := EnvPassEntry{Name: k}
+		if p, ok := matchEnvPattern(k, autoPassEnvs); ok {
+			entry.Status, entry.Rule, entry.Source = EnvPassed, p, "always"
+		} else if p, ok := matchEnvPattern(k, force.NeverPassEnvs); ok {
+			entry.Status, entry.Rule, entry.Source = EnvDenied, p, forceSource
+		} else if p, ok := matchEnvPattern(k, force.AlwaysPassEnvs); ok {
+			entry.Status, entry.Rule, entry.Source = EnvPassed, p, forceSource
+		} else if p, ok := matchEnvPattern(k, c.EnvironmentVariables.Deny); ok {
+			entry.Status, entry.Rule, entry.Source = EnvDenied, p, c.Source("environment-variables.deny")
+		} else if p, ok := matchEnvPattern(k, c.EnvironmentVariables.Pass); ok {
+			entry.Status, entry.Rule, entry.Source = EnvPassed, p, c.Source("environment-variables.pass")
+		} else {
+			continue
+		}
+		byName[k] = entry
+	}
+
+	entries := make([]EnvPassEntry, 0, len(byName))
+	for _, e := range byName {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// Check that environment variable patterns are valid globs
+func validateEnvPatterns(key string, patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return &InputError{
+				Err: fmt.Errorf("invalid pattern in %s: %s", key, p),
+			}
+		}
+	}
+	return nil
+}
+
+// Warn about names in environment-variables.pass (not patterns) that are not set.
+func (c *PlaybookConfig) warnMissingPassEnvs() {
+	for _, k := range c.EnvironmentVariables.Pass {
+		if strings.ContainsAny(k, "*?[") {
+			continue
+		}
+		if _, ok := os.LookupEnv(k); !ok {
+			slog.Warn(fmt.Sprintf("Pass through env not found: %s", k))
+		}
+	}
+}
@@ -25,8 +25,21 @@ var (
 	regExpDblDots  = regexp.MustCompile(`\.\.`)
 	regExpPathName = regexp.MustCompile(`^[a-zA-Z0-9./\-_]+$`)
 	regExpDotSlash = regexp.MustCompile(`^\./`)
+
+	// paths checked by the path policy (see checkPolicyPath) and container mounts
+	regExpPolicyPathName = regexp.MustCompile(`^[a-zA-Z0-9./\-_~@+,:=%]+$`)
 )
 
+// A host path mounted with -v can't contain : or , (they separate the target and the options).
+func checkMountSource(path string) error {
+	if strings.ContainsAny(path, ":,") {
+		return &InputError{
+			Err: fmt.Errorf("path mounted in the container contains : or ,: %s", path),
+		}
+	}
+	return nil
+}
+
 // Check path is valid, no special characters, and no "..".
 func sanitizePath(path string) error {
 
@@ -93,37 +106,6 @@ func ListDir(dir string, exts []string) ([]string, error) {
 	return files, nil
 }
 
-// Note: This function is very Unix-specific (not Windows compatible).
-//
-//	It also may have issues if dir = "/" or with symlinks.
-func WalkDir(root string, exts []string) ([]string, error) {
-	var files []string
-	slog.Debug(fmt.Sprintf("Listing directory: %s", root))
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if d.IsDir() {
-			return nil
-		}
-		for _, s := range exts {
-			if s == "" && !strings.Contains(path, ".") {
-				if !strings.HasPrefix(path, "/") {
-					path = "./" + path
-				}
-				files = append(files, path)
-				return nil
-			}
-			if strings.HasSuffix(path, "."+s) {
-				if !strings.HasPrefix(path, "/") {
-					path = "./" + path
-				}
-				files = append(files, path)
-				return nil
-			}
-		}
-		return nil
-	})
-	return files, err
-}
-
 func checkRelativePath(path string) bool {
 	m := regExpDotSlash.FindString(path)
 	if m != "" {
@@ -230,7 +212,18 @@ func copyFileContents(src, dst string) (err error) {
 	return
 }
 
-func GetContainerRuntime() (string, error) {
+// GetContainerRuntime returns the container runtime binary.  runtime is the configured binary
+// (container.runtime), otherwise podman is used if it exists, then docker.
+func GetContainerRuntime(runtime string) (string, error) {
+
+	if runtime != "" {
+		_, err := exec.LookPath(runtime)
+		if err != nil {
+			return "", fmt.Errorf("container runtime not found: %s", runtime)
+		}
+		slog.Debug(fmt.Sprintf("using configured container runtime %s", runtime))
+		return runtime, nil
+	}
 
 	_, err := exec.LookPath("podman")
 	if err == nil {
@@ -248,6 +241,35 @@ func GetContainerRuntime() (string, error) {
 
 }
 
+// ListContainerImages returns the output of "<runtime> images".  When filter is set,
+// only the header and lines containing filter are returned.
+func ListContainerImages(runtime string, filter string) ([]string, error) {
+
+	containerCmd, err := GetContainerRuntime(runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, outputLines, err := RunBufferedCommand(containerCmd, []string{"images"}, nil, 30, true, "")
+	if err != nil || rc != 0 {
+		return *outputLines, &ExecutionError{
+			Err: fmt.Errorf("%s images failed (rc=%d): %v", containerCmd, rc, err),
+		}
+	}
+
+	var images []string
+	for i, line := range *outputLines {
+		if line == "" {
+			continue
+		}
+		if i > 0 && filter != "" && !strings.Contains(line, filter) {
+			continue
+		}
+		images = append(images, line)
+	}
+	return images, nil
+}
+
 // PrintMemUsage outputs the current, total and OS memory being used. As well as the number
 // of garbage collection cycles completed.
 func PrintMemUsage() {
@@ -265,7 +287,9 @@ func bToMb(b uint64) uint64 {
 	return b / 1024 / 1024
 }
 
-func RunBufferedCommand(command string, cmdArgs []string, timeoutSeconds int, captureOutput bool, captureFilePath string) (int, *[]string, error) {
+// Run a command and print (or capture) its output.  The command runs with env (see commandEnv)
+// or the environment of ansible-tui if env is nil.
+func RunBufferedCommand(command string, cmdArgs []string, env []string, timeoutSeconds int, captureOutput bool, captureFilePath string) (int, *[]string, error) {
 
 	var outputLines []string
 
@@ -290,7 +314,8 @@ func RunBufferedCommand(command string, cmdArgs []string, timeoutSeconds int, ca
 	}()
 
 	cmd := exec.CommandContext(ctx, command, cmdArgs...)
-	slog.Info(cmd.String())
+	cmd.Env = env
+	slog.Info(redact(cmd.String()))
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
@@ -329,12 +354,14 @@ func RunBufferedCommand(command string, cmdArgs []string, timeoutSeconds int, ca
 				//log.Fatal(err)
 			}
 
+			// captured output is returned to the caller instead of printed
 			if captureOutput {
 				outputLines = append(outputLines, strline)
+			} else {
+				fmt.Println(strline)
 			}
-			fmt.Println(strline)
 			if captureFilePath != "" {
-				writer.WriteString(strline + "\n")
+				writer.WriteString(redact(strline) + "\n")
 			}
 
 			if err == io.EOF {
@@ -370,19 +397,32 @@ func RunBufferedCommand(command string, cmdArgs []string, timeoutSeconds int, ca
 	return rc, &outputLines, err
 }
 
+// Arguments of a command run in a container.  The function is called by executeCommandInContainer after
+// paths were changed to the paths inside the container and can add environment variables to env.
+type containerArgsFunc func(c *PlaybookConfig, env map[string]string) []string
+
+// Arguments that don't depend on paths inside the container
+func fixedArgs(args ...string) containerArgsFunc {
+	return func(*PlaybookConfig, map[string]string) []string {
+		return args
+	}
+}
+
+// Run command in the container image.  When command is ansible-tui (see containerHasAnsibleTui), the config
+// is written to a file for ansible-tui inside the container, otherwise command is run directly in the image.
 // TODO: Should make this pointer receiver method on PlaybookConfig struct (uses image, ssh private key, and temp dir path)
-func executeCommandInContainer(c PlaybookConfig, command string, cmdArgs []string, captureOutput bool, captureFilePath string) (int, *[]string, error) {
+func executeCommandInContainer(c PlaybookConfig, command string, cmdArgs containerArgsFunc, timeoutSeconds int, captureOutput bool, captureFilePath string) (int, *[]string, error) {
 
 	var outputLines []string
 
 	slog.Debug("Starting executePlaybookInContainer()")
 
 	// Determine container runtime
-	containerRunCmd, err := GetContainerRuntime()
+	containerRunCmd, err := GetContainerRuntime(c.Container.Runtime)
 	if err != nil {
 		slog.Error(fmt.Sprintf("%s", err))
 		return 1, &outputLines, &ExecutionError{
-			Err: errors.New("container image was specified, but no container runtime could be found (docker or podman)"),
+			Err: fmt.Errorf("container image was specified, but no container runtime could be found: %s", err),
 		}
 	}
 	slog.Info(fmt.Sprintf("Using %s for container runtime", containerRunCmd))
@@ -392,24 +432,31 @@ func executeCommandInContainer(c PlaybookConfig, command string, cmdArgs []strin
 		slog.Error("could not get current working directory")
 		return 1, &outputLines, err
 	}
-	volMount1 := cwd + ":" + "/app:rw,z"
-
-	// Convert relative path to temp container config file to path mounted inside the container
-	containerConfigFile := c.TempDirPath + "/container-config.yml"
-	containerConfigEnvVar := strings.Replace(containerConfigFile, "./", "", 1)
-	containerConfigEnvVar = "PB_CONFIG_FILE=/app/" + containerConfigEnvVar
+	if err := checkMountSource(cwd); err != nil {
+		return 1, &outputLines, err
+	}
+	volMount1 := cwd + ":" + containerWorkDir + ":" + c.mountOptions(containerRunCmd, "rw")
 
 	// Set additional container runtime arguments
 	var containerArgs []string
-	containerArgs = append(containerArgs, "run", "--rm", "-u", "root", "-e", containerConfigEnvVar, "-e", "NO_TUI=true", "-v", volMount1)
+	containerArgs = append(containerArgs, "run", "--rm", "--pull=never", "-w", containerWorkDir)
+	containerArgs = append(containerArgs, c.containerRunArgs(containerRunCmd)...)
+	containerArgs = append(containerArgs, "-v", volMount1)
+
+	// environment resolved on the host (before values are removed from the config below)
+	env := c.containerEnv()
 
 	// Setup SSH if lint is not enabled
 	if !c.LintEnabled {
+		c.applyForcedParams()
 		if c.SshPrivateKeyFile != "" {
 			c.SshPrivateKeyFile, err = filepath.EvalSymlinks(c.SshPrivateKeyFile)
 			if err != nil {
 				return 1, &outputLines, err
 			}
+			if err := checkMountSource(c.SshPrivateKeyFile); err != nil {
+				return 1, &outputLines, err
+			}
 			// set volume mount to normalized location in container and append to command
 			volMount2 := c.SshPrivateKeyFile + ":" + "/app/.ssh/ansible-tui:ro" // using mount option -z/-Z causes lsetxattr error
 			containerArgs = append(containerArgs, "-v", volMount2)
@@ -417,121 +464,101 @@ func executeCommandInContainer(c PlaybookConfig, command string, cmdArgs []strin
 			// modify SSH private key path to location used inside the container
 			c.SshPrivateKeyFile = "/app/.ssh/ansible-tui"
 		}
+		if c.UsesSshAgent() {
+			if err := checkMountSource(c.sshAgentSock); err != nil {
+				return 1, &outputLines, err
+			}
+			// the agent socket is found by ValidateInputs inside the container
+			containerArgs = append(containerArgs, "-v", c.sshAgentSock+":"+containerSshAgentSock, "-e", "SSH_AUTH_SOCK="+containerSshAgentSock)
+		}
 	}
 
-	containerArgs = append(containerArgs, c.Image, command)
-
-	if len(cmdArgs) > 0 {
-		containerArgs = append(containerArgs, cmdArgs...)
+	// files written from *_CONTENTS environment variables and the merged ansible-lint config
+	if c.RunDir != "" {
+		runDir, err := filepath.Abs(c.RunDir)
+		if err != nil {
+			return 1, &outputLines, err
+		}
+		if err := checkMountSource(runDir); err != nil {
+			return 1, &outputLines, err
+		}
+		containerArgs = append(containerArgs, "-v", runDir+":"+containerRunDir+":"+c.mountOptions(containerRunCmd, "ro"))
+		c.InventoryFile = c.containerRunPath(c.InventoryFile)
+		c.ExtraVarsFile = c.containerRunPath(c.ExtraVarsFile)
+		c.LintConfigFile = c.containerRunPath(c.LintConfigFile)
+		c.RunDir = containerRunDir
 	}
 
-	// unset image in PlaybookConfig before marshal for execution inside container
-	c.Image = ""
+	args := cmdArgs(&c, env)
 
-	// write PlaybookConfig just before execution since values were modified accordingly above
-	b, err := yaml.Marshal(&c)
+	// the environment is passed with --env-file, so the config file for the container has no values
+	envFile, envNames, err := writeContainerEnvFile(env)
 	if err != nil {
-		slog.Error("Could not marshal PlaybookConfig to bytes")
 		return 1, &outputLines, err
 	}
-	err = os.WriteFile(containerConfigFile, b, 0600)
-	if err != nil {
-		slog.Error(fmt.Sprintf("Error writing output file: %s", containerConfigFile))
-		return 1, &outputLines, err
-	}
-
-	// TODO: Should run a separate image pull here so container execution time is more predictable relative to supplied timout.
-	// With separate pull can just add a few seconds.
-
-	// Set container execution timeout to unlimited (-1) until above TODO for image pull is working
-	return RunBufferedCommand(containerRunCmd, containerArgs, -1, captureOutput, captureFilePath)
-
-}
+	defer os.Remove(envFile)
+	containerArgs = append(containerArgs, "--env-file", envFile)
 
-func IsPlaybookFile(file string) (bool, error) {
-	// Detection criteria for playbooks:
-	// If file contains "become:", it is a playbook
-	// Playbook files often contain "hosts:", but so do YAML inventory files
-	// Playbooks do NOT contain "^all:" (YAML inventory files do contain ^all:)
-	// Read file and check if it contains "host:"
+	if command == ansibleTuiContainerPath {
+		// Convert relative path to temp container config file to path mounted inside the container
+		containerConfigFile := c.TempDirPath + "/container-config.yml"
+		containerConfigPath := containerWorkDir + "/" + strings.Replace(containerConfigFile, "./", "", 1)
+		containerArgs = append(containerArgs,
+			"-e", "PB_CONFIG_FILE="+containerConfigPath,
+			"-e", containerConfigEnvVar+"="+containerConfigPath,
+			"-e", "NO_TUI=true")
 
-	// read the whole file at once
-	b, err := os.ReadFile(file)
-	if err != nil {
-		return false, err
+		err = writeContainerConfig(c, containerConfigFile, envNames)
+		if err != nil {
+			return 1, &outputLines, err
+		}
+		defer os.Remove(containerConfigFile)
 	}
 
-	s := string(b) // convert bytes to string
-	// check whether s contains "become:"
-	if strings.Contains(s, "become:") {
-		return true, nil
-	}
+	containerArgs = append(containerArgs, c.Image, command)
+	containerArgs = append(containerArgs, args...)
 
-	// Check for "hosts:" and not "^all:" in the file
-	if strings.Contains(s, "hosts:") && !strings.Contains(s, "^all:") {
-		return true, nil
-	}
+	// the image was pulled before (see ensureImage), so the timeout only applies to the run
+	return RunBufferedCommand(containerRunCmd, containerArgs, nil, timeoutSeconds, captureOutput, captureFilePath)
 
-	return false, nil
 }
 
-func IsInventory(file string) (bool, error) {
-	// Checking inventory is more difficult to do efficiently than playbooks.
-	// In this quick check, simple pattern match rules are used to score the file
+// Write the config for ansible-tui inside the container.  envNames are passed through from --env-file.
+func writeContainerConfig(c PlaybookConfig, containerConfigFile string, envNames []string) error {
 
-	// read the whole file at once
-	b, err := os.ReadFile(file)
-	if err != nil {
-		return false, err
-	}
-	s := string(b) // convert bytes to string
+	c.EnvironmentVariables.Pass = envNames
+	c.EnvironmentVariables.Set = nil
+	c.EnvironmentVariables.Deny = nil
 
-	found := false
-	score := 0
-	// This should be enough to catch any YAML inventory files
-	// check for typical inventory file patterns
-	if !strings.Contains(s, "become:") {
-		score++
-	}
-	if strings.Contains(s, "children:") {
-		score += 4
-	}
-	if strings.Contains(s, "all:") {
-		score += 2
-	}
-	if score > 2 {
-		found = true
-		return true, nil
-	}
+	// unset image in PlaybookConfig before marshal for execution inside container
+	c.Image = ""
+	// profiles were already applied to PlaybookConfig
+	c.Profiles = nil
 
-	// Non-YAML inventory files are harder to detect so exclude yml and yaml extensions
-	// that should have been detected above.
-	ext := filepath.Ext(file)
-	if ext == ".yml" || ext == ".yaml" {
-		return false, nil
+	// paths were checked on the host and are in the working directory or a container mount (see checkPolicyPath)
+	c.PathPolicy = PathPolicy{AllowedRoots: []string{"."}, AllowAbsolute: true}
+	for _, m := range c.Container.Mounts {
+		c.PathPolicy.AllowedRoots = append(c.PathPolicy.AllowedRoots, m.Target)
 	}
 
-	if ext == ".ini" {
-		score += 2
+	// write PlaybookConfig just before execution since values were modified by executeCommandInContainer
+	// (values are already interpolated, so $ is escaped to keep them as they are)
+	node := yaml.Node{}
+	err := node.Encode(&c)
+	if err != nil {
+		slog.Error("Could not encode PlaybookConfig")
+		return err
 	}
-
-	// Check for typical inventory file patterns in non-YAML inventory files
-	if strings.Contains(s, "ansible_host=") {
-		score += 2
+	escapeInterpolation(&node)
+	b, err := yaml.Marshal(&node)
+	if err != nil {
+		slog.Error("Could not marshal PlaybookConfig to bytes")
+		return err
 	}
-
-	// Doesn't work
-	// This will match "localhost" or any line with just a hostname pattern on it
-	// regExpHostname := regexp.MustCompile(`(?m)^[A-Za-z_][0-9A-Za-z_\-\_\.]{8,50}$`)
-	// m := regExpHostname.FindString(file)
-	// if m != "" {
-	// 	slog.Debug(fmt.Sprintf("file with: %s (%d)", file, score))
-	// 	score++
-	// }
-
-	if score > 1 {
-		return true, nil
+	err = os.WriteFile(containerConfigFile, b, 0600)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error writing output file: %s", containerConfigFile))
+		return err
 	}
-
-	return found, nil
+	return nil
 }
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	historyFileName string = "history.jsonl"
+
+	// older runs are removed when a run is recorded
+	historyMaxEntries int = 100
+)
+
+// A playbook run recorded in the history file (one JSON object per line).
+type HistoryEntry struct {
+	Time        time.Time `json:"time"`
+	Duration    float64   `json:"duration"` // seconds
+	Playbook    string    `json:"playbook"`
+	Inventory   string    `json:"inventory"`
+	Limit       string    `json:"limit,omitempty"`
+	Profile     string    `json:"profile,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	ImageDigest string    `json:"image-digest,omitempty"`
+	VirtualEnv  string    `json:"virtual-env,omitempty"`
+	ExitCode    int       `json:"exit-code"`
+	Error       string    `json:"error,omitempty"`
+}
+
+func (c *PlaybookConfig) historyFile() string {
+	return filepath.Join(c.TempDirPath, historyFileName)
+}
+
+// RecordRun adds a playbook run that started at start to the history in temp-dir.  Runs of ansible-tui
+// inside a container are recorded by the ansible-tui that started the container.
+func (c *PlaybookConfig) RecordRun(start time.Time, rc int, runErr error) error {
+
+	if isContainerConfig(c.ConfigFilePath) {
+		return nil
+	}
+
+	entry := HistoryEntry{
+		Time:        start,
+		Duration:    time.Since(start).Round(time.Millisecond).Seconds(),
+		Playbook:    c.Playbook,
+		Inventory:   c.InventoryFile,
+		Limit:       c.LimitHost,
+		Profile:     c.Profile,
+		Image:       c.Image,
+		ImageDigest: c.Metrics.ImageDigest,
+		VirtualEnv:  c.VirtualEnvPath,
+		ExitCode:    rc,
+	}
+	if runErr != nil {
+		entry.Error = redact(runErr.Error())
+	}
+
+	entries, err := c.ReadHistory()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Ignoring invalid history file %s: %s", c.historyFile(), err))
+		entries = nil
+	}
+	entries = append(entries, entry)
+	if len(entries) > historyMaxEntries {
+		entries = entries[len(entries)-historyMaxEntries:]
+	}
+
+	var contents strings.Builder
+	for _, e := range entries {
+		b, err := json.Marshal(&e)
+		if err != nil {
+			return err
+		}
+		contents.Write(b)
+		contents.WriteString("\n")
+	}
+
+	err = ensureDir(c.TempDirPath)
+	if err != nil {
+		return err
+	}
+	slog.Debug(fmt.Sprintf("Recording run in history: %s", c.historyFile()))
+	return os.WriteFile(c.historyFile(), []byte(contents.String()), 0600)
+}
+
+// ReadHistory returns the recorded playbook runs, oldest first.  There is no history before the first run.
+func (c *PlaybookConfig) ReadHistory() ([]HistoryEntry, error) {
+
+	var entries []HistoryEntry
+
+	f, err := os.Open(c.historyFile())
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return entries, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		entry := HistoryEntry{}
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// ClearHistory removes the recorded playbook runs.
+func (c *PlaybookConfig) ClearHistory() error {
+	slog.Info(fmt.Sprintf("Clearing history: %s", c.historyFile()))
+	err := os.Remove(c.historyFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// One line of history output
+func (e HistoryEntry) String() string {
+	target := e.Playbook + " -i " + e.Inventory
+	if e.Limit != "" {
+		target += " -l " + e.Limit
+	}
+	runtime := e.VirtualEnv
+	if e.Image != "" {
+		runtime = e.Image
+		if e.ImageDigest != "" {
+			runtime = e.ImageDigest
+		}
+	}
+	line := fmt.Sprintf("%s  rc=%-3d %7.1fs  %s", e.Time.Local().Format("2006-01-02 15:04:05"), e.ExitCode, e.Duration, target)
+	if runtime != "" {
+		line += "  (" + runtime + ")"
+	}
+	if e.Error != "" {
+		line += "  error: " + e.Error
+	}
+	return line
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Values of pull-policy
+const (
+	PullAlways  = "always"
+	PullMissing = "missing" // default
+	PullNever   = "never"
+)
+
+func validatePullPolicy(policy string) error {
+	switch policy {
+	case "", PullAlways, PullMissing, PullNever:
+		return nil
+	}
+	return &InputError{
+		Err: fmt.Errorf("pull-policy must be always, missing or never: %s", policy),
+	}
+}
+
+// True if the container image still has to be pulled (or checked) before a run in a container.
+func (c *PlaybookConfig) NeedsImagePull() bool {
+	if c.Image == "" {
+		return false
+	}
+	return c.pulledImage != c.Image
+}
+
+// PullImage pulls the container image according to pull-policy and writes the progress of the pull to out.
+// The pull is not limited by playbook-timeout.  The digest of the image is stored in Metrics.ImageDigest.
+func (c *PlaybookConfig) PullImage(out io.Writer) error {
+
+	err := validatePullPolicy(c.PullPolicy)
+	if err != nil {
+		return err
+	}
+
+	runtime, err := GetContainerRuntime(c.Container.Runtime)
+	if err != nil {
+		return &ExecutionError{
+			Err: fmt.Errorf("container image was specified, but no container runtime could be found: %s", err),
+		}
+	}
+
+	policy := c.PullPolicy
+	if policy == "" {
+		policy = PullMissing
+	}
+
+	exists := imageExists(runtime, c.Image)
+	switch {
+	case policy == PullNever && !exists:
+		return &ExecutionError{
+			Err: fmt.Errorf("image %s not found and pull-policy is never", c.Image),
+		}
+	case policy == PullAlways, !exists:
+		slog.Info(fmt.Sprintf("Pulling image %s (pull-policy: %s)", c.Image, policy))
+		fmt.Fprintf(out, "Pulling image %s\n", c.Image)
+		pullCmd := exec.Command(runtime, "pull", c.Image)
+		pullCmd.Stdout = out
+		pullCmd.Stderr = out
+		slog.Info(pullCmd.String())
+		if err := pullCmd.Run(); err != nil {
+			return &ExecutionError{
+				Err: fmt.Errorf("%s pull %s failed: %s", runtime, c.Image, err),
+			}
+		}
+	default:
+		slog.Info(fmt.Sprintf("Using local image %s (pull-policy: %s)", c.Image, policy))
+	}
+
+	digest, err := imageDigest(runtime, c.Image)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Could not get digest of image %s: %s", c.Image, err))
+	}
+	c.Metrics.ImageDigest = digest
+	c.pulledImage = c.Image
+	slog.Info(fmt.Sprintf("image %s resolved to %s", c.Image, digest))
+	return nil
+}
+
+// Pull the image before running in a container, unless it was already pulled (ex. by the TUI).
+func (c *PlaybookConfig) ensureImage() error {
+	if !c.NeedsImagePull() {
+		return nil
+	}
+	// progress goes to stderr so captured output (ex. ansible-inventory) is not affected
+	return c.PullImage(os.Stderr)
+}
+
+func imageExists(runtime string, image string) bool {
+	return exec.Command(runtime, "image", "inspect", image).Run() == nil
+}
+
+// Repo digest of an image (ex. quay.io/org/image@sha256:...) or the image ID if it has none (local builds)
+func imageDigest(runtime string, image string) (string, error) {
+	out, err := exec.Command(runtime, "image", "inspect", "--format", "{{range .RepoDigests}}{{.}} {{end}}", image).Output()
+	if err != nil {
+		return "", err
+	}
+	if fields := strings.Fields(string(out)); len(fields) > 0 {
+		return fields[0], nil
+	}
+	out, err = exec.Command(runtime, "image", "inspect", "--format", "{{.Id}}", image).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
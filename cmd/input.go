@@ -16,18 +16,23 @@ type PlaybookMetrics struct {
 	ExitCode       int
 	Error          error
 	InventoryCount int
+	ImageDigest    string // digest of the container image used for the run
 }
 
 type PlaybookEnvironmentVariables struct {
-	Pass []string          `json:"pass"`
-	Set  map[string]string `json:"set"`
+	Pass   []string          `json:"pass"`                                 // names or patterns (ex. ANSIBLE_*)
+	Deny   []string          `yaml:"deny,omitempty" json:"deny,omitempty"` // names or patterns that are never passed
+	Set    map[string]string `json:"set"`
+	Secret []string          `yaml:"secret,omitempty" json:"secret,omitempty"` // names of envs with secret values (in addition to regExpSensitiveKey)
 }
 
 type TuiParams struct {
-	PlaybookDir    string `yaml:"playbook-dir" json:"playbook-dir"`
-	InventoryDir   string `yaml:"inventory-dir" json:"inventory-dir"`
-	ImageFilter    string `yaml:"image-filter" json:"image-filter"`
-	VirtualEnvsDir string `yaml:"virtual-envs-dir" json:"virtual-envs-dir"`
+	PlaybookDir    string   `yaml:"playbook-dir" json:"playbook-dir"`
+	InventoryDir   string   `yaml:"inventory-dir" json:"inventory-dir"`
+	ImageFilter    string   `yaml:"image-filter" json:"image-filter"`
+	VirtualEnvsDir string   `yaml:"virtual-envs-dir" json:"virtual-envs-dir"`
+	Include        []string `yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude        []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
 }
 
 // struct for storing and passing playbook configurations (marshal, unmarshal, methods, function calls)
@@ -35,6 +40,7 @@ type PlaybookConfig struct {
 	Playbook             string                       `yaml:"playbook" json:"playbook"`
 	VerboseLevel         int                          `yaml:"verbose-level" json:"verbose-level"`
 	SshPrivateKeyFile    string                       `yaml:"ssh-private-key-file" json:"ssh-private-key-file"`
+	SshAgent             string                       `yaml:"ssh-agent,omitempty" json:"ssh-agent,omitempty"`
 	RemoteUser           string                       `yaml:"remote-user" json:"remote-user"`
 	InventoryFile        string                       `yaml:"inventory" json:"inventory"`
 	LimitHost            string                       `yaml:"limit" json:"limit"`
@@ -45,13 +51,25 @@ type PlaybookConfig struct {
 	WindowsGroup         string                       `yaml:"windows-group" json:"windows-group"`
 	Image                string                       `yaml:"image" json:"image"`
 	VirtualEnvPath       string                       `yaml:"virtual-env-path" json:"virtual-env-path"`
+	ExecutionType        string                       `yaml:"execution-type,omitempty" json:"execution-type,omitempty"` // documented key, the image is used when both are set
+	PullPolicy           string                       `yaml:"pull-policy,omitempty" json:"pull-policy,omitempty"`
+	Container            ContainerConfig              `yaml:"container,omitempty" json:"container,omitempty"`
 	PlaybookTimeout      int                          `yaml:"playbook-timeout" json:"playbook-timeout"`
 	EnvironmentVariables PlaybookEnvironmentVariables `yaml:"environment-variables"`
+	PathPolicy           PathPolicy                   `yaml:"path-policy,omitempty" json:"path-policy,omitempty"`
 	Metrics              PlaybookMetrics
 	TempDirPath          string `yaml:"temp-dir-path" json:"temp-dir-path"`
 	ConfigFilePath       string
 	Tui                  TuiParams `yaml:"tui" json:"tui"`
 	LintEnabled          bool
+	LintConfigFile       string               `yaml:"lintconfigfile,omitempty" json:"-"`
+	RunDir               string               `yaml:"rundir,omitempty" json:"-"`
+	Profile              string               `yaml:"-" json:"-"`
+	Profiles             map[string]yaml.Node `yaml:"profiles,omitempty" json:"-"`
+	Sources              map[string]string    `yaml:"-" json:"-"`
+	templates            map[string]configTemplate
+	pulledImage          string // image already pulled by PullImage
+	sshAgentSock         string // SSH agent socket used for the run
 }
 
 type InputError struct {
@@ -86,6 +104,7 @@ func (c *PlaybookConfig) ReadEnvs() error {
 	playbook := os.Getenv("PLAYBOOK")
 	if playbook != "" {
 		c.Playbook = playbook
+		c.setSource("playbook", SourceEnv+" (PLAYBOOK)")
 	}
 
 	verboseLevel := os.Getenv("VERBOSE_LEVEL")
@@ -96,6 +115,7 @@ func (c *PlaybookConfig) ReadEnvs() error {
 			verboseLevelInt = 1
 		} else {
 			c.VerboseLevel = verboseLevelInt
+			c.setSource("verbose-level", SourceEnv+" (VERBOSE_LEVEL)")
 		}
 		// TODO: should probably evaluate this right away in a separate function and set log level
 	}
@@ -103,6 +123,7 @@ func (c *PlaybookConfig) ReadEnvs() error {
 	tmpDirPath := os.Getenv("TMP_DIR_PATH")
 	if tmpDirPath != "" {
 		c.TempDirPath = tmpDirPath
+		c.setSource("temp-dir-path", SourceEnv+" (TMP_DIR_PATH)")
 	}
 
 	// create temp-dir if it does not exist
@@ -123,33 +144,52 @@ func (c *PlaybookConfig) ReadEnvs() error {
 	virtualEnvPath := os.Getenv("VIRTUAL_ENV")
 	if virtualEnvPath != "" {
 		c.VirtualEnvPath = virtualEnvPath
+		c.setSource("virtual-env-path", SourceEnv+" (VIRTUAL_ENV)")
 	}
 
 	containerImage := os.Getenv("CONTAINER_IMAGE")
 	if containerImage != "" {
 		c.Image = containerImage
+		c.setSource("image", SourceEnv+" (CONTAINER_IMAGE)")
 	}
 
-	// sshPrivateKeyContents := os.Getenv("SSH_PRIVATE_KEY_CONTENTS")
-	// if sshPrivateKeyContents != "" {
-	// 	c.SshPrivateKeyFile = "./ssh-private-key"
-	// 	// write out contents to file
-	// 	err := WriteFileFromString(c.SshPrivateKeyFile, sshPrivateKeyContents, 0600)
-	// 	if err != nil {
-	// 		slog.Error("could not write ssh private key file from ssh private key contents")
-	// 		return err
-	// 	}
-	// 	slog.Debug(fmt.Sprintf("Wrote SSH private key file from SSH_PRIVATE_KEY_CONTENTS to %s", c.SshPrivateKeyFile))
-	// }
+	containerRuntime := os.Getenv("CONTAINER_RUNTIME")
+	if containerRuntime != "" {
+		c.Container.Runtime = containerRuntime
+		c.setSource("container.runtime", SourceEnv+" (CONTAINER_RUNTIME)")
+	}
 
 	sshPrivateKeyFile := os.Getenv("SSH_PRIVATE_KEY_FILE")
+	sshPrivateKeyContents := os.Getenv("SSH_PRIVATE_KEY_CONTENTS")
+	if sshPrivateKeyFile != "" && sshPrivateKeyContents != "" {
+		return &InputError{
+			Err: errors.New("only one SSH private key environment variable is allowed"),
+		}
+	}
+
 	if sshPrivateKeyFile != "" {
 		c.SshPrivateKeyFile = sshPrivateKeyFile
+		c.setSource("ssh-private-key-file", SourceEnv+" (SSH_PRIVATE_KEY_FILE)")
+	}
+
+	if sshPrivateKeyContents != "" {
+		// ssh requires a trailing newline after the key
+		if !strings.HasSuffix(sshPrivateKeyContents, "\n") {
+			sshPrivateKeyContents += "\n"
+		}
+		c.SshPrivateKeyFile, err = c.writeRunFile("ssh-private-key", sshPrivateKeyContents)
+		if err != nil {
+			slog.Error("could not write ssh private key file from ssh private key contents")
+			return err
+		}
+		c.setSource("ssh-private-key-file", SourceEnv+" (SSH_PRIVATE_KEY_CONTENTS)")
+		slog.Debug(fmt.Sprintf("Wrote SSH private key file from SSH_PRIVATE_KEY_CONTENTS to %s", c.SshPrivateKeyFile))
 	}
 
 	remoteUser := os.Getenv("ANSIBLE_REMOTE_USER")
 	if remoteUser != "" {
 		c.RemoteUser = remoteUser
+		c.setSource("remote-user", SourceEnv+" (ANSIBLE_REMOTE_USER)")
 	}
 
 	inventoryFile := os.Getenv("INVENTORY_FILE")
@@ -159,13 +199,14 @@ func (c *PlaybookConfig) ReadEnvs() error {
 	invInputCount := 0
 	if inventoryFile != "" {
 		c.InventoryFile = inventoryFile
+		c.setSource("inventory", SourceEnv+" (INVENTORY_FILE)")
 		invInputCount++
 	}
 
 	if inventoryContents != "" {
-		c.InventoryFile = "./hosts-INVENTORY"
-		// write out contents to file
-		err := WriteFileFromString(c.InventoryFile, inventoryContents, 0600)
+		c.setSource("inventory", SourceEnv+" (INVENTORY_CONTENTS)")
+		// write out contents to file in the run directory
+		c.InventoryFile, err = c.writeRunFile("hosts-INVENTORY", inventoryContents)
 		if err != nil {
 			slog.Error("could not write inventory file from inventory contents")
 			return err
@@ -181,6 +222,7 @@ func (c *PlaybookConfig) ReadEnvs() error {
 
 	if inventoryUrl != "" {
 		c.InventoryFile = "./hosts-INVENTORY"
+		c.setSource("inventory", SourceEnv+" (INVENTORY_URL)")
 		// TODO: get inventory from url and write to file
 		invInputCount++
 	}
@@ -194,20 +236,22 @@ func (c *PlaybookConfig) ReadEnvs() error {
 	limitHost := os.Getenv("LIMIT_HOST")
 	if limitHost != "" {
 		c.LimitHost = limitHost
+		c.setSource("limit", SourceEnv+" (LIMIT_HOST)")
 	}
 
 	varInputCount := 0
 	extraVarsFile := os.Getenv("EXTRA_VARS_FILE")
 	if extraVarsFile != "" {
 		c.ExtraVarsFile = extraVarsFile
+		c.setSource("extra-vars-file", SourceEnv+" (EXTRA_VARS_FILE)")
 		varInputCount++
 	}
 
 	extraVarsContents := os.Getenv("EXTRA_VARS_CONTENTS")
 	if extraVarsContents != "" {
-		c.ExtraVarsFile = "PLAYBOOK-extravars"
-		// write out contents to file
-		err := WriteFileFromString(c.ExtraVarsFile, extraVarsContents, 0600)
+		c.setSource("extra-vars-file", SourceEnv+" (EXTRA_VARS_CONTENTS)")
+		// write out contents to file in the run directory
+		c.ExtraVarsFile, err = c.writeRunFile("PLAYBOOK-extravars", extraVarsContents)
 		if err != nil {
 			slog.Error("could not write extra-vars file from extra-vars contents")
 			return err
@@ -228,26 +272,117 @@ func (c *PlaybookConfig) ReadEnvs() error {
 			slog.Error("Could not convert ANSIBLE_PLAYBOOK_TIMEOUT to integer")
 			return err
 		}
+		c.setSource("playbook-timeout", SourceEnv+" (ANSIBLE_PLAYBOOK_TIMEOUT)")
 	}
 
 	ansibleTags := os.Getenv("ANSIBLE_TAGS")
 	if ansibleTags != "" {
 		c.AnsibleTags = ansibleTags
+		c.setSource("tags", SourceEnv+" (ANSIBLE_TAGS)")
 	}
 
 	ansibleSkipTags := os.Getenv("ANSIBLE_SKIP_TAGS")
 	if ansibleSkipTags != "" {
 		c.AnsibleSkipTags = ansibleSkipTags
+		c.setSource("skip-tags", SourceEnv+" (ANSIBLE_SKIP_TAGS)")
 	}
 
 	extraArgs := os.Getenv("EXTRA_ARGS")
 	if extraArgs != "" {
 		c.ExtraArgs = extraArgs
+		c.setSource("extra-args", SourceEnv+" (EXTRA_ARGS)")
 	}
 
 	windowsGroups := os.Getenv("WINDOWS_GROUP")
 	if windowsGroups != "" {
 		c.WindowsGroup = windowsGroups
+		c.setSource("windows-group", SourceEnv+" (WINDOWS_GROUP)")
+	}
+
+	tuiPlaybookDir := os.Getenv("TUI_PLAYBOOK_DIR")
+	if tuiPlaybookDir != "" {
+		c.Tui.PlaybookDir = tuiPlaybookDir
+		c.setSource("tui.playbook-dir", SourceEnv+" (TUI_PLAYBOOK_DIR)")
+	}
+
+	tuiInventoryDir := os.Getenv("TUI_INVENTORY_DIR")
+	if tuiInventoryDir != "" {
+		c.Tui.InventoryDir = tuiInventoryDir
+		c.setSource("tui.inventory-dir", SourceEnv+" (TUI_INVENTORY_DIR)")
+	}
+
+	tuiVirtualEnvsDir := os.Getenv("TUI_VIRTUAL_ENVS_DIR")
+	if tuiVirtualEnvsDir != "" {
+		c.Tui.VirtualEnvsDir = tuiVirtualEnvsDir
+		c.setSource("tui.virtual-envs-dir", SourceEnv+" (TUI_VIRTUAL_ENVS_DIR)")
+	}
+
+	// TUI_IMAGE_FILTER="" is allowed to display all images
+	if tuiImageFilter, ok := os.LookupEnv("TUI_IMAGE_FILTER"); ok {
+		c.Tui.ImageFilter = tuiImageFilter
+		c.setSource("tui.image-filter", SourceEnv+" (TUI_IMAGE_FILTER)")
+	}
+
+	return nil
+}
+
+// VirtualEnvsPath returns virtual-envs-dir with ~ expanded to the HOME directory.
+func (t TuiParams) VirtualEnvsPath() string {
+	if strings.HasPrefix(t.VirtualEnvsDir, "~") {
+		return strings.Replace(t.VirtualEnvsDir, "~", os.Getenv("HOME"), 1)
+	}
+	return t.VirtualEnvsDir
+}
+
+// ValidateTuiParams checks the directories used by the TUI to list playbooks, inventories and
+// virtual environments.  Empty values are allowed.
+func (c *PlaybookConfig) ValidateTuiParams() error {
+
+	for _, d := range []struct{ key, path string }{
+		{"tui.playbook-dir", c.Tui.PlaybookDir},
+		{"tui.inventory-dir", c.Tui.InventoryDir},
+	} {
+		if d.path == "" {
+			continue
+		}
+		slog.Info(fmt.Sprintf("Checking %s: %s", d.key, d.path))
+		err := sanitizePath(d.path)
+		if err != nil {
+			return &InputError{
+				Err: fmt.Errorf("%s: %w", d.key, err),
+			}
+		}
+		if filepath.IsAbs(d.path) {
+			return &InputError{
+				Err: fmt.Errorf("%s must have relative path to current directory: %s", d.key, d.path),
+			}
+		}
+		if ok, err := pathExists(d.path, true); !ok {
+			return &InputError{
+				Err: fmt.Errorf("%s: %w", d.key, err),
+			}
+		}
+	}
+
+	if c.Tui.VirtualEnvsDir != "" {
+		virtualEnvsPath := c.Tui.VirtualEnvsPath()
+		slog.Info(fmt.Sprintf("Checking tui.virtual-envs-dir: %s", virtualEnvsPath))
+		err := sanitizePath(virtualEnvsPath)
+		if err != nil {
+			return &InputError{
+				Err: fmt.Errorf("tui.virtual-envs-dir: %w", err),
+			}
+		}
+		if !filepath.IsAbs(virtualEnvsPath) {
+			return &InputError{
+				Err: fmt.Errorf("tui.virtual-envs-dir must be an absolute path: %s", c.Tui.VirtualEnvsDir),
+			}
+		}
+		if ok, err := pathExists(virtualEnvsPath, true); !ok {
+			return &InputError{
+				Err: fmt.Errorf("tui.virtual-envs-dir: %w", err),
+			}
+		}
 	}
 
 	return nil
@@ -263,16 +398,15 @@ func (c *PlaybookConfig) ReadConf(pbConfigFile string) error {
 		return nil
 	}
 
-	buf, err := os.ReadFile(pbConfigFile)
+	err := c.readConfFile(pbConfigFile, SourceProject)
 	if err != nil {
-		slog.Error(fmt.Sprintf("Error reading config file %s: %s", pbConfigFile, err))
 		return err
 	}
 
-	//c := &Config{}
-	err = yaml.Unmarshal(buf, c)
+	// overlay values from the selected profile (-p or PB_PROFILE)
+	err = c.applyProfile()
 	if err != nil {
-		slog.Error(fmt.Sprintf("Error unmarshalling config file %s: %s", pbConfigFile, err))
+		slog.Error(fmt.Sprintf("Error applying profile from config file %s: %s", pbConfigFile, err))
 		return err
 	}
 
@@ -281,71 +415,148 @@ func (c *PlaybookConfig) ReadConf(pbConfigFile string) error {
 
 func (c *PlaybookConfig) ProcessEnvs() error {
 
-	// This function manipulates environment variables.
+	// This function resolves the environment variables for ansible.
 	// It should be run after all inputs (envs and config files) are evaluated
 	// and the final PlaybookConfig struct is set.
 	// It should be run before any os/exec commands are run for containers
 	// or running ansible.
+	// The environment of ansible-tui is not changed, commands are run with commandEnv.
 
-	envPass := make(map[string]string)
-	envKeyIdx := make(map[string]bool)
-
-	// These env keys are required for a default shell and should not be unset
-	autoPassEnvs := make(map[string]bool)
-	autoPassEnvs["PATH"] = true
-	autoPassEnvs["HOME"] = true
+	// environment variables forced by the global config can't be overridden
+	force, err := readGlobalForce()
+	if err != nil {
+		return err
+	}
 
-	// TODO: Should auto-pass variables with ANSIBLE_* prefix?  Optional?  Global config file?
+	patterns := map[string][]string{
+		"environment-variables.pass":        c.EnvironmentVariables.Pass,
+		"environment-variables.deny":        c.EnvironmentVariables.Deny,
+		"always-pass-environment-variables": force.AlwaysPassEnvs,
+		"never-pass-environment-variables":  force.NeverPassEnvs,
+	}
+	for k, p := range patterns {
+		err = validateEnvPatterns(k, p)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Pass through environment variables listed under environment-variables.pass
-	// Capture values to be able to pass into container.
-	for _, k := range c.EnvironmentVariables.Pass {
-		if v := os.Getenv(k); v != "" {
-			slog.Debug(fmt.Sprintf("Caching pass through env %s", k))
-			envPass[k] = v // save to map before setting later
-		} else {
-			slog.Warn(fmt.Sprintf("Pass through env not found: %s", k))
+	// The config is not changed, commandEnv resolves the values of set and passed variables for each command.
+	c.warnMissingPassEnvs()
+	for _, e := range c.envPassList(force) {
+		switch e.Status {
+		case EnvSet:
+			v, ok := force.EnvironmentVariables[e.Name]
+			if current, set := c.EnvironmentVariables.Set[e.Name]; !ok {
+				v = current
+			} else if set && current != v {
+				slog.Warn(fmt.Sprintf("Env %s is forced by global config, ignoring value from config: %s", e.Name, c.redactEnv(e.Name, current)))
+			}
+			slog.Debug(fmt.Sprintf("Set env %s = %s", e.Name, c.redactEnv(e.Name, v)))
+		case EnvPassed:
+			slog.Debug(fmt.Sprintf("Passing through env %s (%s)", e.Name, e.Rule))
+		case EnvDenied:
+			if _, ok := c.EnvironmentVariables.Set[e.Name]; ok {
+				slog.Warn(fmt.Sprintf("Env %s is not allowed by %s (%s), ignoring value from config", e.Name, e.Rule, e.Source))
+			}
+			slog.Debug(fmt.Sprintf("Denied env %s (%s)", e.Name, e.Rule))
 		}
 	}
 
-	// set anything in environment-variables.set
-	for k, v := range c.EnvironmentVariables.Set {
-		_, ok := envPass[k]
-		if ok {
-			slog.Warn(fmt.Sprintf("Set env also exists in pass, overriding with set value: %s", k))
-			delete(envPass, k)
+	// mask secret values in log output from here on
+	c.registerSecrets()
+
+	return nil
+}
+
+// PlaybookConfig method to validate captured inputs from PlaybookConfig struct
+func (c *PlaybookConfig) ValidateInputs() error {
+
+	err := c.validateRuntime()
+	if err != nil {
+		return err
+	}
+
+	// Skip the rest (SSH and inventory) when running ansible-lint (local)
+	if c.LintEnabled && c.Playbook == "" {
+		slog.Info("Skipping rest of ValidateInputs for ansible-lint of entire repository")
+		return nil
+	}
+
+	if c.Playbook == "" {
+		return &InputError{
+			Err: errors.New("playbook is required"),
 		}
-		slog.Debug(fmt.Sprintf("Set env %s = %s", k, v))
-		os.Setenv(k, v)
-		envKeyIdx[k] = true
 	}
 
-	// set any pass through variables that were not overidden by a set variable
-	for k, v := range envPass {
-		slog.Debug(fmt.Sprintf("Passing through env %s", k))
-		c.EnvironmentVariables.Set[k] = v
-		envKeyIdx[k] = true
+	slog.Info(fmt.Sprintf("Checking playbook path: %s", c.Playbook))
+	c.Playbook, err = c.checkPolicyPath("playbook", c.Playbook)
+	if err != nil {
+		return err
 	}
 
-	// clear list of "pass through" variables since they were converted to "set"
-	c.EnvironmentVariables.Pass = c.EnvironmentVariables.Pass[:0]
+	// Skip the rest (SSH and inventory) when running ansible-lint (local)
+	if c.LintEnabled {
+		slog.Info("Skipping rest of ValidateInputs for ansible-lint of playbook")
+		return nil
+	}
 
-	// delete all other environment variables
-	for _, e := range os.Environ() {
-		pair := strings.SplitN(e, "=", 2)
-		if _, ok1 := envKeyIdx[pair[0]]; !ok1 {
-			if _, ok2 := autoPassEnvs[pair[0]]; !ok2 {
-				slog.Debug(fmt.Sprintf("Unsetting unspecified env: %s", pair[0]))
-				os.Unsetenv(pair[0])
-			}
+	err = c.validateInventoryFile()
+	if err != nil {
+		return err
+	}
+
+	var absPath string
+
+	if c.ExtraVarsFile != "" {
+		slog.Info(fmt.Sprintf("Checking extra-vars file path: %s", c.ExtraVarsFile))
+		c.ExtraVarsFile, err = c.checkPolicyPath("extra-vars-file", c.ExtraVarsFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.SshPrivateKeyFile != "" {
+		slog.Info(fmt.Sprintf("Checking SSH private key path: %s", c.SshPrivateKeyFile))
+		if strings.HasPrefix(c.SshPrivateKeyFile, "~") {
+			home := os.Getenv("HOME")
+			c.SshPrivateKeyFile = strings.Replace(c.SshPrivateKeyFile, "~", home, 1)
+		}
+		err := sanitizePath(c.SshPrivateKeyFile)
+		if err != nil {
+			absPath, _ = filepath.Abs(c.SshPrivateKeyFile)
+			slog.Error(fmt.Sprintf("Error sanitizing path to SSH private key: %s", absPath))
+			return err
+		}
+		absPath, _ = filepath.Abs(c.SshPrivateKeyFile)
+		if ok, err := pathExists(absPath, false); !ok {
+			slog.Error(fmt.Sprintf("SSH private key file path does not exist: %s", absPath))
+			return err
 		}
+		// when container execution, the absoluate path is used to mount to a specific container path
+		c.SshPrivateKeyFile = absPath
+	}
+
+	err = c.resolveSshAgent()
+	if err != nil {
+		return err
+	}
+
+	// ANSIBLE_CONFIG, ./ansible.cfg and ~/.ansible.cfg override /etc/ansible/ansible.cfg
+	force, err := readGlobalForce()
+	if err != nil {
+		return err
+	}
+	err = c.checkAnsibleCfgPolicy(force)
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
-// PlaybookConfig method to validate captured inputs from PlaybookConfig struct
-func (c *PlaybookConfig) ValidateInputs() error {
+// Validate the execution environment (virtual environment or container image) and verbosity.
+func (c *PlaybookConfig) validateRuntime() error {
 
 	if c.VerboseLevel < 0 || c.VerboseLevel > 7 {
 		slog.Warn("VERBOSE_LEVEL must be between 0 and 7, using default (1).")
@@ -361,10 +572,7 @@ func (c *PlaybookConfig) ValidateInputs() error {
 		slog.SetLogLoggerLevel(slog.LevelDebug)
 	}
 
-	var (
-		err     error
-		absPath string
-	)
+	var absPath string
 
 	execTypeCount := 0
 	if c.VirtualEnvPath != "" {
@@ -377,11 +585,24 @@ func (c *PlaybookConfig) ValidateInputs() error {
 		execTypeCount++
 	}
 
+	err := validatePullPolicy(c.PullPolicy)
+	if err != nil {
+		return err
+	}
+
 	if execTypeCount > 1 {
 		slog.Warn("Container image was specified, unsetting virtual environment")
 		c.VirtualEnvPath = ""
 	}
 
+	if c.Image != "" {
+		runtime, _ := GetContainerRuntime(c.Container.Runtime)
+		err = c.validateContainer(runtime)
+		if err != nil {
+			return err
+		}
+	}
+
 	if c.VirtualEnvPath != "" {
 		slog.Info(fmt.Sprintf("Checking Python virtual environment path: %s", c.VirtualEnvPath))
 		if strings.HasPrefix(c.VirtualEnvPath, "~") {
@@ -415,39 +636,12 @@ func (c *PlaybookConfig) ValidateInputs() error {
 		execTypeCount++
 	}
 
-	// Skip the rest (SSH and inventory) when running ansible-lint (local)
-	if c.LintEnabled && c.Playbook == "" {
-		slog.Info("Skipping rest of ValidateInputs for ansible-lint of entire repository")
-		return nil
-	}
+	return nil
+}
 
-	if c.Playbook == "" {
-		return &InputError{
-			Err: errors.New("playbook is required"),
-		}
-	}
+func (c *PlaybookConfig) validateInventoryFile() error {
 
-	slog.Info(fmt.Sprintf("Checking playbook path: %s", c.Playbook))
-	err = sanitizePath(c.Playbook)
-	if err != nil {
-		slog.Error(fmt.Sprintf("sanitizing playbook path: %s", c.Playbook))
-		return err
-	}
-	if ok := checkRelativePath(c.Playbook); !ok {
-		return &InputError{
-			Err: errors.New("playbook must have relative path to current directory"),
-		}
-	}
-	if ok, err := pathExists(c.Playbook, false); !ok {
-		slog.Error(fmt.Sprintf("Path for playbook does not exist: %s", c.Playbook))
-		return err
-	}
-
-	// Skip the rest (SSH and inventory) when running ansible-lint (local)
-	if c.LintEnabled {
-		slog.Info("Skipping rest of ValidateInputs for ansible-lint of playbook")
-		return nil
-	}
+	var err error
 
 	if c.InventoryFile == "" {
 		return &InputError{
@@ -456,64 +650,10 @@ func (c *PlaybookConfig) ValidateInputs() error {
 	}
 
 	slog.Info(fmt.Sprintf("Checking inventory file path: %s", c.InventoryFile))
-	err = sanitizePath(c.InventoryFile)
+	c.InventoryFile, err = c.checkPolicyPath("inventory", c.InventoryFile)
 	if err != nil {
-		slog.Error(fmt.Sprintf("Error sanitizing inventory file path: %s", c.InventoryFile))
 		return err
 	}
-	if ok := checkRelativePath(c.InventoryFile); !ok {
-		return &InputError{
-			Err: errors.New("inventory-file must have relative path to current directory"),
-		}
-	}
-	if ok, err := pathExists(c.InventoryFile, false); !ok {
-		slog.Error(fmt.Sprintf("Inventory path file does not exist: %s", c.InventoryFile))
-		return err
-	}
-
-	if c.ExtraVarsFile != "" {
-		slog.Info(fmt.Sprintf("Checking extra-vars file path: %s", c.ExtraVarsFile))
-		err := sanitizePath(c.ExtraVarsFile)
-		if err != nil {
-			slog.Error(fmt.Sprintf("sanitizing extra-vars path: %s", c.ExtraVarsFile))
-			return err
-		}
-		if ok := checkRelativePath(c.ExtraVarsFile); !ok {
-			return &InputError{
-				Err: errors.New("extra-vars file must have relative path to current directory"),
-			}
-		}
-		if ok, err := pathExists(c.ExtraVarsFile, false); !ok {
-			slog.Error(fmt.Sprintf("Path for extra-vars file does not exist: %s", c.ExtraVarsFile))
-			return err
-		}
-	}
-
-	if c.SshPrivateKeyFile != "" {
-		slog.Info(fmt.Sprintf("Checking SSH private key path: %s", c.SshPrivateKeyFile))
-		if strings.HasPrefix(c.SshPrivateKeyFile, "~") {
-			home := os.Getenv("HOME")
-			c.SshPrivateKeyFile = strings.Replace(c.SshPrivateKeyFile, "~", home, 1)
-		}
-		err := sanitizePath(c.SshPrivateKeyFile)
-		if err != nil {
-			absPath, _ = filepath.Abs(c.SshPrivateKeyFile)
-			slog.Error(fmt.Sprintf("Error sanitizing path to SSH private key: %s", absPath))
-			return err
-		}
-		absPath, _ = filepath.Abs(c.SshPrivateKeyFile)
-		if ok, err := pathExists(absPath, false); !ok {
-			slog.Error(fmt.Sprintf("SSH private key file path does not exist: %s", absPath))
-			return err
-		}
-		// when container execution, the absoluate path is used to mount to a specific container path
-		c.SshPrivateKeyFile = absPath
-	}
-
-	// TODO: To enforce /etc/ansible/ansible.cfg in container image, disallow (and log warning) for any of these:
-	// ANSIBLE_CONFIG (environment variable if set)
-	// ansible.cfg (in the current directory)
-	// ~/.ansible.cfg (in the home directory)
 
 	return nil
 }
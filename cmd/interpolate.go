@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Original value of a config value that was changed by interpolation.
+type configTemplate struct {
+	raw   string
+	value string
+}
+
+// ${VAR}, ${VAR:-default} or $$ (literal $)
+var regExpInterpolation = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Expand ${VAR} and ${VAR:-default} in s.  The default is used when VAR is unset or empty
+// and $$ is a literal $.  Any other $ is left as it is.
+func interpolate(s string) (string, error) {
+
+	var b strings.Builder
+	last := 0
+	for _, m := range regExpInterpolation.FindAllStringSubmatchIndex(s, -1) {
+		gap := s[last:m[0]]
+		if i := strings.Index(gap, "${"); i >= 0 {
+			return "", fmt.Errorf("invalid variable reference: %s", s[last+i:])
+		}
+		b.WriteString(gap)
+		last = m[1]
+
+		if s[m[0]:m[1]] == "$$" {
+			b.WriteString("$")
+			continue
+		}
+
+		name := s[m[2]:m[3]]
+		value := os.Getenv(name)
+		if value == "" && m[4] >= 0 {
+			value = s[m[6]:m[7]]
+		} else if _, ok := os.LookupEnv(name); !ok && m[4] < 0 {
+			slog.Warn(fmt.Sprintf("Environment variable %s is not set, using empty string", name))
+		}
+		b.WriteString(value)
+	}
+
+	if i := strings.Index(s[last:], "${"); i >= 0 {
+		return "", fmt.Errorf("invalid variable reference: %s", s[last+i:])
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// Walk the values of mappings in a config node (including profiles) with their dotted keys.
+// Values in lists (environment-variables.pass, tui.include, etc.) are not included.
+func walkConfigValues(node *yaml.Node, prefix string, fn func(key string, value *yaml.Node)) {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		walkConfigValues(node.Content[0], prefix, fn)
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		value := node.Content[i+1]
+		switch value.Kind {
+		case yaml.MappingNode:
+			walkConfigValues(value, key, fn)
+		case yaml.ScalarNode:
+			fn(key, value)
+		}
+	}
+}
+
+// Expand environment variables in the values of a config file node.  The original values are
+// kept so they can be written back instead of the expanded values (RevertInterpolation).
+func (c *PlaybookConfig) interpolateNode(file string, node *yaml.Node) error {
+
+	var err error
+	walkConfigValues(node, "", func(key string, value *yaml.Node) {
+		if err != nil || !strings.Contains(value.Value, "$") {
+			return
+		}
+		expanded, e := interpolate(value.Value)
+		if e != nil {
+			err = &InputError{
+				Err: &ConfigError{File: file, Line: value.Line, Column: value.Column, Msg: fmt.Sprintf("%s: %s", key, e)},
+			}
+			return
+		}
+		if c.templates == nil {
+			c.templates = make(map[string]configTemplate)
+		}
+		c.templates[key] = configTemplate{raw: value.Value, value: expanded}
+
+		// the type of the expanded value is resolved again (ex. verbose-level: ${VERBOSE})
+		value.Value = expanded
+		value.Tag = ""
+		value.Style = 0
+	})
+
+	return err
+}
+
+// RevertInterpolation replaces expanded values in a config node with their original ${VAR}
+// references before the config is written to a file.  Values that were changed or didn't come
+// from a config file have $ escaped as $$ so they are read back as they are.
+func (c *PlaybookConfig) RevertInterpolation(node *yaml.Node) {
+	walkConfigValues(node, "", func(key string, value *yaml.Node) {
+		if t, ok := c.templates[key]; ok && value.Value == t.value {
+			value.Value = t.raw
+			value.Tag = "!!str"
+			return
+		}
+		escapeValue(value)
+	})
+}
+
+// Escape $ in every value of a config node that is read again by ansible-tui (ex. in a container).
+func escapeInterpolation(node *yaml.Node) {
+	walkConfigValues(node, "", func(_ string, value *yaml.Node) {
+		escapeValue(value)
+	})
+}
+
+func escapeValue(value *yaml.Node) {
+	if strings.Contains(value.Value, "$") {
+		value.Value = strings.ReplaceAll(value.Value, "$", "$$")
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"io"
 	"log"
 	"log/slog"
-	"os"
 	"os/exec"
 	"regexp"
 )
@@ -15,6 +14,9 @@ import (
 var (
 	regExpInvGroup = regexp.MustCompile(`\@([\w\._-]+)\:$`) // are there char restrictions on group names?
 	regExpInvHost  = regexp.MustCompile(`\|--([\w\._-]+)$`) // character limits based on DNS names (RFC 1035)
+
+	// ansible-inventory returns 0 when it can't parse an inventory, the warning is the only indication
+	regExpInvParseError = regexp.MustCompile(`: Unable to parse`)
 )
 
 func (c *PlaybookConfig) validateAnsibleInventory() error {
@@ -38,15 +40,15 @@ func (c *PlaybookConfig) validateAnsibleInventory() error {
 	// 	return err
 	// }
 
+	env := c.commandEnv()
 	if c.VirtualEnvPath != "" {
-		os.Setenv("PATH", c.VirtualEnvPath+"/bin:"+os.Getenv("PATH"))
 		slog.Debug("Using virtualenv for " + ansibleInvCmdPath)
 	}
 
-	path, err := exec.LookPath(ansibleInvCmdPath)
+	path, err := lookPath(ansibleInvCmdPath, env)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("%s lookup err: %s", ansibleInvCmdPath, err))
-		slog.Info(os.Getenv("PATH"))
+		slog.Info(env["PATH"])
 		return err
 	} else {
 		slog.Info(fmt.Sprintf("%s lookup path: %s", ansibleInvCmdPath, path))
@@ -56,9 +58,10 @@ func (c *PlaybookConfig) validateAnsibleInventory() error {
 
 	ansibleInventoryArgs = append(ansibleInventoryArgs, "-i", c.InventoryFile, "--graph")
 
-	cmd := exec.Command(ansibleInvCmdPath, ansibleInventoryArgs...)
+	cmd := exec.Command(path, ansibleInventoryArgs...)
+	cmd.Env = envList(env)
 
-	slog.Info(cmd.String())
+	slog.Info(redact(cmd.String()))
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
@@ -81,8 +84,7 @@ func (c *PlaybookConfig) validateAnsibleInventory() error {
 	// Store ansible_host in a map to deduplicate, then count map keys.
 
 	var (
-		regExpParseError = regexp.MustCompile(`: Unable to parse`)
-		ansibleHosts     = make(map[string]bool) // used to deduplicate host to be counted
+		ansibleHosts = make(map[string]bool) // used to deduplicate host to be counted
 	)
 
 	defer cmd.Wait()
@@ -97,7 +99,7 @@ func (c *PlaybookConfig) validateAnsibleInventory() error {
 			}
 
 			// Look for ": Unable to parse"
-			m := regExpParseError.FindString(strline)
+			m := regExpInvParseError.FindString(strline)
 			if m != "" {
 				slog.Error("Parse error detected, setting return code = 1.  Set")
 				if c.VerboseLevel < 3 {
@@ -106,7 +108,7 @@ func (c *PlaybookConfig) validateAnsibleInventory() error {
 				rc = 1
 			}
 
-			slog.Debug(strline)
+			slog.Debug(redact(strline))
 			// fmt.Println(strline)
 
 			if err == io.EOF {
@@ -135,7 +137,7 @@ func (c *PlaybookConfig) validateAnsibleInventory() error {
 			if m != "" {
 				ansibleHosts[m] = true
 			}
-			slog.Debug(strline)
+			slog.Debug(redact(strline))
 			// fmt.Println(strline)
 
 			if err == io.EOF {
@@ -186,36 +188,105 @@ func (c *PlaybookConfig) GetAnsibleInventory(invFilePath string) (*[]string, err
 
 	ansibleInvCmdPath := "ansible-inventory"
 
+	// return cached results if the inventory files have not changed since the last run
+	if outputLines = c.readInventoryCache(invFilePath); outputLines != nil {
+		return outputLines, nil
+	}
+
 	var ansibleInvArgs []string
 	ansibleInvArgs = append(ansibleInvArgs, "-i", invFilePath, "--graph")
 
 	// container image was specified
 	if c.Image != "" {
-		rc, outputLines, err = executeCommandInContainer(*c, ansibleInvCmdPath, ansibleInvArgs, true, "")
+		if err := c.ensureImage(); err != nil {
+			return &[]string{}, err
+		}
+		// files in the run directory are mounted at a different path in the container
+		containerArgs := []string{"-i", c.containerRunPath(invFilePath), "--graph"}
+		rc, outputLines, err = executeCommandInContainer(*c, ansibleInvCmdPath, fixedArgs(containerArgs...), -1, true, "")
 		slog.Info(fmt.Sprintf("Finished running ansible-inventory in container: rc=%d", rc))
+		c.cacheInventoryResult(invFilePath, rc, outputLines, err)
 		return outputLines, err
 	}
 
+	env := c.commandEnv()
 	if c.VirtualEnvPath != "" {
-		os.Setenv("PATH", c.VirtualEnvPath+"/bin:"+os.Getenv("PATH"))
 		slog.Info("Using virtualenv for " + ansibleInvCmdPath)
 	}
 
-	path, err := exec.LookPath(ansibleInvCmdPath)
+	path, err := lookPath(ansibleInvCmdPath, env)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("%s lookup err: %s", ansibleInvCmdPath, err))
-		slog.Info(os.Getenv("PATH"))
+		slog.Info(env["PATH"])
 		return nil, err
 	} else {
 		slog.Info(fmt.Sprintf("%s lookup path: %s", ansibleInvCmdPath, path))
 	}
 
-	rc, outputLines, err = RunBufferedCommand(ansibleInvCmdPath, ansibleInvArgs, 30, true, "")
+	rc, outputLines, err = RunBufferedCommand(path, ansibleInvArgs, envList(env), 30, true, "")
 	slog.Info(fmt.Sprintf("Finished running ansible-inventory: rc=%d", rc))
+	c.cacheInventoryResult(invFilePath, rc, outputLines, err)
 	return outputLines, err
 
 }
 
+// Only successful ansible-inventory results are cached so errors are re-evaluated on the next call.
+func (c *PlaybookConfig) cacheInventoryResult(invFilePath string, rc int, outputLines *[]string, err error) {
+	if rc != 0 || err != nil || outputLines == nil {
+		return
+	}
+	for _, line := range *outputLines {
+		if regExpInvParseError.MatchString(line) {
+			return
+		}
+	}
+	if err := c.writeInventoryCache(invFilePath, *outputLines); err != nil {
+		slog.Warn(fmt.Sprintf("Could not write inventory cache for %s: %s", invFilePath, err))
+	}
+}
+
+// InventoryGraph validates the inventory inputs and returns the ansible-inventory --graph output for InventoryFile.
+func (c *PlaybookConfig) InventoryGraph() (*[]string, error) {
+
+	err := c.validateRuntime()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.validateInventoryFile()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetAnsibleInventory(c.InventoryFile)
+}
+
+// VerifyInventory returns an InputError if ansible-inventory can't parse InventoryFile.
+// The number of hosts is stored in Metrics.InventoryCount.
+func (c *PlaybookConfig) VerifyInventory() error {
+
+	outputLines, err := c.InventoryGraph()
+	if err != nil {
+		return err
+	}
+
+	ansibleHosts := make(map[string]bool)
+	for _, line := range *outputLines {
+		if regExpInvParseError.MatchString(line) {
+			return &InputError{
+				Err: fmt.Errorf("inventory is not valid: %s", line),
+			}
+		}
+		if m := regExpInvHost.FindStringSubmatch(line); len(m) > 1 {
+			ansibleHosts[m[1]] = true
+		}
+	}
+	c.Metrics.InventoryCount = len(ansibleHosts)
+	slog.Info(fmt.Sprintf("inventory count: %d", c.Metrics.InventoryCount))
+
+	return nil
+}
+
 func EvaluateInventoryGraphEntry(line string) (string, string, error) {
 
 	m := regExpInvHost.FindStringSubmatch(line)
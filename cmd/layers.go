@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration layers in order of precedence (lowest to highest).
+// Sources are recorded per field so -explain-config can show where each value came from.
+const (
+	SourceDefault = "default"
+	SourceSystem  = "system config"
+	SourceUser    = "user config"
+	SourceProject = "project config"
+	SourceProfile = "profile"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+var (
+	// Values of environment variables with names matching this pattern are redacted when displayed.
+	// Whole name segments are matched (DB_PASSWORD, GITHUB_TOKEN but not SSH_AUTH_SOCK or PASSTHROUGH).
+	regExpSensitiveKey = regexp.MustCompile(`(?i)(^|_)(PASSWORD|PASSWD|SECRET|TOKEN|API_?KEY|PRIVATE_KEY|CREDENTIALS?)(_|$)`)
+
+	// Names of environment variables that hold a path (ex. ANSIBLE_VAULT_PASSWORD_FILE) and not the secret itself
+	regExpPathKey = regexp.MustCompile(`(?i)_(FILE|PATH|DIR)$`)
+)
+
+// Path to the user-level config file ($XDG_CONFIG_HOME/ansible-tui/config.yml or ~/.config/ansible-tui/config.yml).
+func UserConfigPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "ansible-tui", "config.yml")
+}
+
+func (c *PlaybookConfig) setSource(key string, source string) {
+	if c.Sources == nil {
+		c.Sources = make(map[string]string)
+	}
+	c.Sources[key] = source
+}
+
+// Source returns where the value for key (ex. "remote-user", "tui.playbook-dir") was set.
+func (c *PlaybookConfig) Source(key string) string {
+	if s, ok := c.Sources[key]; ok {
+		return s
+	}
+	return SourceDefault
+}
+
+// LoadConfig reads every configuration file layer into PlaybookConfig: global defaults
+// (/etc/ansible/ansible-tui-config.yml), user config, project config (pbConfigFile) and the selected profile.
+// Environment variables (ReadEnvs) and CLI flags are applied afterwards by the caller.
+func (c *PlaybookConfig) LoadConfig(pbConfigFile string) error {
+
+	err := c.readGlobalDefaults()
+	if err != nil {
+		return err
+	}
+
+	userConfigPath := UserConfigPath()
+	if ok, _ := pathExists(userConfigPath, false); ok {
+		err = c.readConfFile(userConfigPath, SourceUser)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.ReadConf(pbConfigFile)
+}
+
+func (c *PlaybookConfig) readGlobalDefaults() error {
+
+	if ok, _ := pathExists(ansibleTuiGlobalConfigPath, false); !ok {
+		return nil
+	}
+
+	d, err := ReadGlobalConfig()
+	if err != nil {
+		return err
+	}
+
+	if d.Defaults.Kind == 0 {
+		return nil
+	}
+
+	err = d.Defaults.Decode(c)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error unmarshalling global defaults from %s: %s", ansibleTuiGlobalConfigPath, err))
+		return err
+	}
+	c.recordNodeSources(&d.Defaults, fmt.Sprintf("%s (%s)", SourceSystem, ansibleTuiGlobalConfigPath))
+
+	return nil
+}
+
+// Unmarshal a YAML config file on top of the current values and record the keys it sets.
+func (c *PlaybookConfig) readConfFile(pbConfigFile string, source string) error {
+
+	slog.Debug(fmt.Sprintf("Reading %s file: %s", source, pbConfigFile))
+
+	buf, err := os.ReadFile(pbConfigFile)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error reading config file %s: %s", pbConfigFile, err))
+		return err
+	}
+
+	node, err := c.parseConfFile(pbConfigFile, buf)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Invalid config file: %s", pbConfigFile))
+		return err
+	}
+
+	err = node.Decode(c)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error unmarshalling config file %s: %s", pbConfigFile, err))
+		return err
+	}
+	c.recordNodeSources(node, fmt.Sprintf("%s (%s)", source, pbConfigFile))
+
+	return nil
+}
+
+// Parse a config file, expand environment variables (${VAR}) and reject unknown keys and
+// values with the wrong type instead of ignoring them.
+func (c *PlaybookConfig) parseConfFile(pbConfigFile string, buf []byte) (*yaml.Node, error) {
+
+	node := yaml.Node{}
+	err := yaml.Unmarshal(buf, &node)
+	if err != nil {
+		return nil, &InputError{
+			Err: fmt.Errorf("%s: %w", pbConfigFile, err),
+		}
+	}
+
+	err = c.interpolateNode(pbConfigFile, &node)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateConfigDocument(pbConfigFile, &node, playbookConfigType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &node, nil
+}
+
+func (c *PlaybookConfig) recordNodeSources(node *yaml.Node, source string) {
+	walkConfigNode(node, "", func(key string, _ *yaml.Node) {
+		c.setSource(key, source)
+	})
+}
+
+// Walk a YAML config node and call fn for each leaf with its dotted key (ex. "tui.playbook-dir").
+// Lists are treated as leaves and the profiles section is skipped.
+func walkConfigNode(node *yaml.Node, prefix string, fn func(key string, value *yaml.Node)) {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		walkConfigNode(node.Content[0], prefix, fn)
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if prefix == "" && (internalConfigKeys[key] || key == "profiles") {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		value := node.Content[i+1]
+		if value.Kind == yaml.MappingNode && len(value.Content) > 0 {
+			walkConfigNode(value, key, fn)
+			continue
+		}
+		fn(key, value)
+	}
+}
+
+// ExplainConfig returns every effective configuration value with the layer it came from.
+// Values of sensitive keys (passwords, tokens, etc.) are redacted.
+func (c *PlaybookConfig) ExplainConfig() (string, error) {
+
+	node := yaml.Node{}
+	err := node.Encode(c)
+	if err != nil {
+		return "", err
+	}
+
+	type entry struct{ key, value, source string }
+	var entries []entry
+	width := 0
+
+	walkConfigNode(&node, "", func(key string, value *yaml.Node) {
+		v := value.Value
+		if value.Kind != yaml.ScalarNode {
+			b, _ := yaml.Marshal(value)
+			v = strings.TrimSpace(string(b))
+			v = strings.ReplaceAll(v, "\n", " ")
+		}
+		if v != "" && v != "[]" && c.isSensitiveKey(key) {
+			v = "********"
+		}
+		entries = append(entries, entry{key, v, c.Source(key)})
+		if len(key) > width {
+			width = len(key)
+		}
+	})
+
+	lines := []string{fmt.Sprintf("config file: %s", c.ConfigFilePath)}
+	if c.Profile != "" {
+		lines = append(lines, fmt.Sprintf("profile: %s", c.Profile))
+	}
+	lines = append(lines, "")
+	for _, e := range entries {
+		lines = append(lines, fmt.Sprintf("%-*s = %-30s # %s", width, e.key, e.value, e.source))
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
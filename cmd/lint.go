@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
 	"strings"
 )
 
@@ -21,23 +20,19 @@ func (c *PlaybookConfig) RunAnsibleLint(target string) (int, error) {
 
 	rc := 0
 
-	ansibleLintFilePath := ""
-
-	if ok, _ := pathExists(ansibleTuiGlobalConfigPath, false); ok {
-		d, err := ReadGlobalConfig()
-		if err == nil {
-			if d.Force.AnsibleLintFilePath != "" {
-				ansibleLintFilePath = d.Force.AnsibleLintFilePath
-			}
+	// ansible-lint config file is already set when running inside a container
+	lintConfigFile := c.LintConfigFile
+	if lintConfigFile == "" {
+		force, err := readGlobalForce()
+		if err != nil {
+			return 1, err
 		}
-	}
-
-	// TODO: If ansibleLintFilePath is still "" AND AnsibleLintFileUrl is defined,
-	// download the file from AnsibleLintFileUrl.
-
-	if ansibleLintFilePath != "" {
-		if ok, _ := pathExists(ansibleLintFilePath, false); ok {
-			copyFileContents(ansibleLintFilePath, ".ansible-lint")
+		lintConfigFile, err = c.ResolveLintConfig(force)
+		if err != nil {
+			return 1, err
+		}
+		if c.inRunDir(lintConfigFile) {
+			defer os.Remove(lintConfigFile)
 		}
 	}
 
@@ -47,46 +42,73 @@ func (c *PlaybookConfig) RunAnsibleLint(target string) (int, error) {
 		if target == "." {
 			args = []string{"-la"}
 		}
-		rc, _, err = executeCommandInContainer(*c, "/bin/ansible-tui", args, false, "")
-		slog.Info(fmt.Sprintf("Finished executePlaybookInContainer: rc=%d", rc))
+		if err := c.ensureImage(); err != nil {
+			return 1, err
+		}
+		containerConfig := *c
+		containerConfig.LintConfigFile = lintConfigFile
+		if c.containerHasAnsibleTui() {
+			rc, _, err = executeCommandInContainer(containerConfig, ansibleTuiContainerPath, fixedArgs(args...), -1, false, "")
+			slog.Info(fmt.Sprintf("Finished executePlaybookInContainer: rc=%d", rc))
+			return rc, err
+		}
+
+		// ansible-lint is run directly in the image (ex. an execution environment without ansible-tui)
+		rc, _, err = executeCommandInContainer(containerConfig, "ansible-lint", func(cc *PlaybookConfig, env map[string]string) []string {
+			if env["ANSIBLE_FORCE_COLOR"] == "" {
+				env["ANSIBLE_FORCE_COLOR"] = "True"
+			}
+			return cc.ansibleLintArgs(target, cc.LintConfigFile)
+		}, -1, false, "")
+		slog.Info(fmt.Sprintf("Finished running ansible-lint in container: rc=%d", rc))
 		return rc, err
 	}
 
 	ansibleLintCmdPath := "ansible-lint"
 
+	env := c.commandEnv()
 	if c.VirtualEnvPath != "" {
-		os.Setenv("PATH", c.VirtualEnvPath+"/bin:"+os.Getenv("PATH"))
 		slog.Info("Using virtualenv for " + ansibleLintCmdPath)
 	}
 
-	path, err := exec.LookPath(ansibleLintCmdPath)
+	path, err := lookPath(ansibleLintCmdPath, env)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("%s lookup err: %s", ansibleLintCmdPath, err))
-		slog.Info(os.Getenv("PATH"))
+		slog.Info(env["PATH"])
 		return 1, err
 	} else {
 		slog.Info(fmt.Sprintf("%s lookup path: %s", ansibleLintCmdPath, path))
 	}
 
-	ansibleLintArgs := []string{"-v", "-p"}
+	ansibleLintArgs := c.ansibleLintArgs(target, lintConfigFile)
 
-	if ok, _ := pathExists(".ansible-lint", false); ok {
-		ansibleLintArgs = append(ansibleLintArgs, "-c", ".ansible-lint")
-	}
-
-	// otherwise the color get's lost in Go's tty/command
-	os.Setenv("ANSIBLE_FORCE_COLOR", "True")
-
-	if target != "." {
-		ansibleLintArgs = append(ansibleLintArgs, c.Playbook)
+	// otherwise the color get's lost in Go's tty/command (unless set in environment-variables.set)
+	if env["ANSIBLE_FORCE_COLOR"] == "" {
+		env["ANSIBLE_FORCE_COLOR"] = "True"
 	}
 
 	// join ansibleLintCmdPath and ansibleLintArgs and print command
 	ansibleLintCmd := ansibleLintCmdPath + " " + strings.Join(ansibleLintArgs, " ")
 	slog.Info(fmt.Sprintf("Running: %s", ansibleLintCmd))
 
-	// return RunBufferedCommandWithoutCapture(ansibleCmdPath, ansiblePlaybookArgs, c.PlaybookTimeout)
-	rc, _, err = RunBufferedCommand(ansibleLintCmdPath, ansibleLintArgs, c.PlaybookTimeout, false, "")
+	// playbook-timeout only applies to ansible-playbook
+	rc, _, err = RunBufferedCommand(path, ansibleLintArgs, envList(env), -1, false, "")
 	return rc, err
 
 }
+
+// Arguments of the ansible-lint command for target (. for all files or the playbook)
+func (c *PlaybookConfig) ansibleLintArgs(target string, lintConfigFile string) []string {
+
+	ansibleLintArgs := []string{"-v", "-p"}
+
+	if lintConfigFile != "" {
+		ansibleLintArgs = append(ansibleLintArgs, "-c", lintConfigFile)
+	}
+
+	if target != "." {
+		ansibleLintArgs = append(ansibleLintArgs, c.Playbook)
+	}
+
+	return ansibleLintArgs
+}
@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	lintPolicyCacheDirName string        = "lint-policy-cache"
+	lintPolicyCacheTTL     time.Duration = time.Hour
+	lintPolicyTimeout      time.Duration = 30 * time.Second
+
+	// ansible-lint config file (in the run directory) written when the enforced rules are merged with the project config
+	lintMergedConfigPattern string = "ansible-lint-merged-*.yml"
+
+	// project ansible-lint config files in the order ansible-lint looks for them
+	lintProjectConfigFiles = []string{".ansible-lint", ".ansible-lint.yml", ".ansible-lint.yaml", ".config/ansible-lint.yml", ".config/ansible-lint.yaml"}
+)
+
+// ansible-lint rules enforced by the global config (ansible-lint-file-path or ansible-lint-file-url).
+type lintPolicy struct {
+	Source  string
+	Version string
+	Content []byte
+}
+
+// Read the enforced ansible-lint rules from the global config.  Returns nil if there are none.
+// ansible-lint-file-path is used if it exists, otherwise ansible-lint-file-url is downloaded (or read from the cache).
+func (c *PlaybookConfig) readLintPolicy(force GlobalConfigForce) (*lintPolicy, error) {
+
+	var (
+		p   *lintPolicy
+		err error
+	)
+
+	if force.AnsibleLintFilePath != "" {
+		if ok, _ := pathExists(force.AnsibleLintFilePath, false); ok {
+			buf, err := os.ReadFile(force.AnsibleLintFilePath)
+			if err != nil {
+				return nil, err
+			}
+			p = &lintPolicy{Source: force.AnsibleLintFilePath, Content: buf}
+		} else {
+			slog.Warn(fmt.Sprintf("ansible-lint-file-path does not exist: %s", force.AnsibleLintFilePath))
+		}
+	}
+
+	if p == nil && force.AnsibleLintFileUrl != "" {
+		p, err = c.downloadLintPolicy(force.AnsibleLintFileUrl, force.AnsibleLintFileChecksum)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p == nil {
+		return nil, nil
+	}
+
+	sum := sha256.Sum256(p.Content)
+	if force.AnsibleLintFileChecksum != "" && !checksumMatches(sum[:], force.AnsibleLintFileChecksum) {
+		return nil, &ExecutionError{
+			Err: fmt.Errorf("checksum of ansible-lint policy %s does not match ansible-lint-file-checksum", p.Source),
+		}
+	}
+
+	p.Version = force.AnsibleLintPolicyVersion
+	if p.Version == "" {
+		p.Version = "sha256:" + hex.EncodeToString(sum[:])[:12]
+	}
+
+	return p, nil
+}
+
+// Checksum is "sha256:<hex>" or "<hex>"
+func checksumMatches(sum []byte, checksum string) bool {
+	checksum = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(checksum)), "sha256:")
+	return hex.EncodeToString(sum) == checksum
+}
+
+// Download the enforced ansible-lint rules from url.  The file is cached in temp-dir and reused while it
+// matches the checksum (or for lintPolicyCacheTTL without a checksum).  The cached file is used if the download fails.
+func (c *PlaybookConfig) downloadLintPolicy(url string, checksum string) (*lintPolicy, error) {
+
+	urlSum := sha256.Sum256([]byte(url))
+	cacheFile := filepath.Join(c.TempDirPath, lintPolicyCacheDirName, hex.EncodeToString(urlSum[:])[:16]+".yml")
+
+	cached, cacheErr := os.ReadFile(cacheFile)
+	if cacheErr == nil {
+		sum := sha256.Sum256(cached)
+		fresh := false
+		if checksum != "" {
+			fresh = checksumMatches(sum[:], checksum)
+		} else if stat, err := os.Stat(cacheFile); err == nil {
+			fresh = time.Since(stat.ModTime()) < lintPolicyCacheTTL
+		}
+		if fresh {
+			slog.Debug(fmt.Sprintf("Using cached ansible-lint policy for %s: %s", url, cacheFile))
+			return &lintPolicy{Source: url, Content: cached}, nil
+		}
+	}
+
+	slog.Info(fmt.Sprintf("Downloading ansible-lint policy: %s", url))
+	buf, err := httpGet(url)
+	if err != nil {
+		if cacheErr == nil {
+			slog.Warn(fmt.Sprintf("Could not download ansible-lint policy, using cached file %s: %s", cacheFile, err))
+			return &lintPolicy{Source: url, Content: cached}, nil
+		}
+		return nil, &ExecutionError{
+			Err: fmt.Errorf("could not download ansible-lint policy %s: %w", url, err),
+		}
+	}
+
+	// don't cache a file that doesn't match the checksum
+	sum := sha256.Sum256(buf)
+	if checksum != "" && !checksumMatches(sum[:], checksum) {
+		return nil, &ExecutionError{
+			Err: fmt.Errorf("checksum of ansible-lint policy %s does not match ansible-lint-file-checksum", url),
+		}
+	}
+
+	err = ensureDir(filepath.Dir(cacheFile))
+	if err == nil {
+		err = os.WriteFile(cacheFile, buf, 0600)
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Could not cache ansible-lint policy %s: %s", cacheFile, err))
+	}
+
+	return &lintPolicy{Source: url, Content: buf}, nil
+}
+
+func httpGet(url string) ([]byte, error) {
+	client := http.Client{Timeout: lintPolicyTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// ResolveLintConfig returns the ansible-lint config file for a run: the project config file or, when force
+// enforces ansible-lint rules, the project config merged with them in the run directory ("" if there is neither).
+func (c *PlaybookConfig) ResolveLintConfig(force GlobalConfigForce) (string, error) {
+
+	policy, err := c.readLintPolicy(force)
+	if err != nil {
+		return "", err
+	}
+
+	projectFile := projectLintConfigFile()
+	if policy == nil {
+		return projectFile, nil
+	}
+
+	lintConfigFile, err := c.writeMergedLintConfig(policy, projectFile)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("ansible-lint policy: %s (version %s)\n", policy.Source, policy.Version)
+	slog.Info(fmt.Sprintf("ansible-lint policy %s (version %s) merged into %s", policy.Source, policy.Version, lintConfigFile))
+	return lintConfigFile, nil
+}
+
+// Project ansible-lint config file or "" if there is none.
+func projectLintConfigFile() string {
+	for _, f := range lintProjectConfigFiles {
+		if ok, _ := pathExists(f, false); ok {
+			return f
+		}
+	}
+	return ""
+}
+
+// Merge the enforced ansible-lint rules with the project config.  Values from the policy take
+// precedence, lists are combined and rules enabled by the policy can't be skipped or only warned about.
+func mergeLintConfig(project map[string]interface{}, policy map[string]interface{}) map[string]interface{} {
+
+	merged := mergeLintValues(project, policy).(map[string]interface{})
+
+	if enabled, ok := policy["enable_list"].([]interface{}); ok {
+		for _, k := range []string{"skip_list", "warn_list"} {
+			if l, ok := merged[k].([]interface{}); ok {
+				merged[k] = removeLintRules(l, enabled)
+			}
+		}
+	}
+
+	return merged
+}
+
+func mergeLintValues(project interface{}, policy interface{}) interface{} {
+	switch p := policy.(type) {
+	case map[string]interface{}:
+		m, ok := project.(map[string]interface{})
+		if !ok {
+			return p
+		}
+		merged := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			merged[k] = v
+		}
+		for k, v := range p {
+			merged[k] = mergeLintValues(m[k], v)
+		}
+		return merged
+	case []interface{}:
+		l, ok := project.([]interface{})
+		if !ok {
+			return p
+		}
+		merged := append([]interface{}{}, l...)
+		for _, v := range p {
+			if !containsLintValue(merged, v) {
+				merged = append(merged, v)
+			}
+		}
+		return merged
+	}
+	return policy
+}
+
+func containsLintValue(l []interface{}, v interface{}) bool {
+	for _, x := range l {
+		if fmt.Sprint(x) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeLintRules(l []interface{}, rules []interface{}) []interface{} {
+	var kept []interface{}
+	for _, v := range l {
+		if containsLintValue(rules, v) {
+			slog.Warn(fmt.Sprintf("ansible-lint rule %v is enforced by global config and can't be skipped", v))
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept
+}
+
+// Write the project ansible-lint config merged with the policy to a new file (0600) in the run directory.
+func (c *PlaybookConfig) writeMergedLintConfig(p *lintPolicy, projectFile string) (string, error) {
+
+	policy := map[string]interface{}{}
+	err := yaml.Unmarshal(p.Content, &policy)
+	if err != nil {
+		return "", &ExecutionError{
+			Err: fmt.Errorf("could not parse ansible-lint policy %s: %w", p.Source, err),
+		}
+	}
+
+	project := map[string]interface{}{}
+	if projectFile != "" {
+		buf, err := os.ReadFile(projectFile)
+		if err != nil {
+			return "", err
+		}
+		err = yaml.Unmarshal(buf, &project)
+		if err != nil {
+			return "", &InputError{
+				Err: fmt.Errorf("could not parse %s: %w", projectFile, err),
+			}
+		}
+	}
+
+	b, err := yaml.Marshal(mergeLintConfig(project, policy))
+	if err != nil {
+		return "", err
+	}
+	header := fmt.Sprintf("# generated by ansible-tui: %s merged with policy %s (version %s)\n", projectFile, p.Source, p.Version)
+	if projectFile == "" {
+		header = fmt.Sprintf("# generated by ansible-tui: policy %s (version %s)\n", p.Source, p.Version)
+	}
+
+	dir, err := c.runDirPath()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, lintMergedConfigPattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = f.WriteString(header + string(b))
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
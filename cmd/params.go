@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SetParam sets a single PlaybookConfig value by its config file key (ex. "limit", "playbook-timeout")
+// and records where it came from.  This is used for layers that set individual values such as CLI flags.
+func (c *PlaybookConfig) SetParam(key string, value string, source string) error {
+
+	var err error
+
+	switch key {
+	case "playbook":
+		c.Playbook = value
+	case "inventory":
+		c.InventoryFile = value
+	case "limit":
+		c.LimitHost = value
+	case "tags":
+		c.AnsibleTags = value
+	case "skip-tags":
+		c.AnsibleSkipTags = value
+	case "extra-vars-file":
+		c.ExtraVarsFile = value
+	case "extra-args":
+		c.ExtraArgs = value
+	case "image":
+		c.Image = value
+	case "virtual-env-path":
+		c.VirtualEnvPath = value
+	case "pull-policy":
+		c.PullPolicy = value
+	case "remote-user":
+		c.RemoteUser = value
+	case "ssh-private-key-file":
+		c.SshPrivateKeyFile = value
+	case "ssh-agent":
+		c.SshAgent = value
+	case "windows-group":
+		c.WindowsGroup = value
+	case "playbook-timeout":
+		c.PlaybookTimeout, err = strconv.Atoi(value)
+	case "verbose-level":
+		c.VerboseLevel, err = strconv.Atoi(value)
+	default:
+		return &InputError{
+			Err: fmt.Errorf("unknown parameter: %s", key),
+		}
+	}
+
+	if err != nil {
+		return &InputError{
+			Err: fmt.Errorf("%s must be an integer: %s", key, value),
+		}
+	}
+
+	c.setSource(key, source)
+	return nil
+}
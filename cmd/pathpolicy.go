@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Paths of the playbook, inventory and extra-vars file must be under one of the allowed roots
+// (default: the current directory).  Absolute paths must be allowed with allow-absolute.
+type PathPolicy struct {
+	AllowedRoots  []string `yaml:"allowed-roots" json:"allowed-roots"`
+	AllowAbsolute bool     `yaml:"allow-absolute" json:"allow-absolute"`
+}
+
+// Path policy enforced by the global config.  It can only tighten the path policy of the config file.
+type PathPolicyForce struct {
+	AllowedRoots []string `yaml:"allowed-roots" json:"allowed-roots"`
+	DenyAbsolute bool     `yaml:"deny-absolute" json:"deny-absolute"`
+}
+
+// Rule for paths of container runs, only the current directory and container.mounts are mounted in the container
+const containerPathRule = "image (only the current directory and container.mounts are mounted in the container)"
+
+// Host directory that is mounted in the container and its path in the container
+type containerRoot struct {
+	source string
+	target string
+}
+
+// Directories mounted in the container: the current directory (the working directory in the
+// container, target ".") and the sources of container.mounts.
+func (c *PlaybookConfig) containerRoots() []containerRoot {
+	roots := []containerRoot{{source: absRoots([]string{"."})[0], target: "."}}
+	for _, m := range c.Container.Mounts {
+		if source := absRoots([]string{m.Source}); len(source) > 0 {
+			roots = append(roots, containerRoot{source: source[0], target: filepath.Clean(m.Target)})
+		}
+	}
+	return roots
+}
+
+// First directory mounted in the container that contains path or nil
+func containerRootOf(path string, roots []containerRoot) *containerRoot {
+	for i := range roots {
+		if pathUnder(path, []string{roots[i].source}) {
+			return &roots[i]
+		}
+	}
+	return nil
+}
+
+// Error for a path that breaks a rule of the path policy
+type PathPolicyError struct {
+	Key  string // config key (ex. inventory)
+	Path string
+	Msg  string
+	Rule string // rule that was broken (ex. path-policy.allow-absolute)
+}
+
+func (e *PathPolicyError) Error() string {
+	return fmt.Sprintf("%s: %s %s (rule: %s)", e.Key, e.Path, e.Msg, e.Rule)
+}
+
+// Expand ~ at the beginning of a path to the home directory
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
+
+// Absolute, cleaned paths of the allowed roots
+func absRoots(roots []string) []string {
+	var abs []string
+	for _, r := range roots {
+		p, err := filepath.Abs(expandHome(r))
+		if err == nil {
+			abs = append(abs, p)
+		}
+	}
+	return abs
+}
+
+func pathUnder(path string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Roots with symlinks resolved (roots that don't exist are kept as they are)
+func resolveRoots(roots []string) []string {
+	resolved := make([]string, 0, len(roots))
+	for _, r := range roots {
+		if p, err := filepath.EvalSymlinks(r); err == nil {
+			r = p
+		}
+		resolved = append(resolved, r)
+	}
+	return resolved
+}
+
+// Check a path (playbook, inventory or extra-vars file) against the path policy and the path policy
+// forced by the global config.  Returns the path with ~ expanded.  The path must exist, which is only
+// checked after the policy so the error doesn't tell whether a file outside the allowed roots exists.
+// For container runs, the path must be under the current directory or the source of a container mount.
+// Absolute paths under the current directory are returned relative to it and paths under a mount are
+// returned as the path in the container.
+func (c *PlaybookConfig) checkPolicyPath(key string, path string) (string, error) {
+
+	// files written by ansible-tui from *_CONTENTS environment variables
+	if c.inRunDir(path) {
+		if ok, err := pathExists(path, false); !ok {
+			slog.Error(fmt.Sprintf("Path for %s does not exist: %s", key, path))
+			return path, err
+		}
+		return path, nil
+	}
+
+	force, err := readGlobalForce()
+	if err != nil {
+		return path, err
+	}
+
+	fail := func(msg string, rule string) (string, error) {
+		return path, &InputError{
+			Err: &PathPolicyError{Key: key, Path: path, Msg: msg, Rule: rule},
+		}
+	}
+
+	path = expandHome(path)
+	if !regExpPolicyPathName.MatchString(path) {
+		return fail("contains invalid characters", "letters, digits and ./-_~@+,:=% only")
+	}
+
+	if filepath.IsAbs(path) {
+		if force.PathPolicy.DenyAbsolute {
+			return fail("is an absolute path", fmt.Sprintf("force path-policy.deny-absolute in %s", ansibleTuiGlobalConfigPath))
+		}
+		if !c.PathPolicy.AllowAbsolute {
+			return fail("is an absolute path", "path-policy.allow-absolute")
+		}
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path, err
+	}
+
+	roots := c.PathPolicy.AllowedRoots
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	userRoots := absRoots(roots)
+	forceRoots := absRoots(force.PathPolicy.AllowedRoots)
+	userRule := fmt.Sprintf("path-policy.allowed-roots %v", roots)
+	forceRule := fmt.Sprintf("force path-policy.allowed-roots %v in %s", force.PathPolicy.AllowedRoots, ansibleTuiGlobalConfigPath)
+
+	if !pathUnder(absPath, userRoots) {
+		return fail("is not under an allowed root", userRule)
+	}
+	if len(forceRoots) > 0 && !pathUnder(absPath, forceRoots) {
+		return fail("is not under an allowed root", forceRule)
+	}
+	var mounted *containerRoot
+	if c.Image != "" {
+		mounted = containerRootOf(absPath, c.containerRoots())
+		if mounted == nil {
+			return fail("is outside the current directory and container.mounts", containerPathRule)
+		}
+	}
+
+	if ok, err := pathExists(absPath, false); !ok {
+		slog.Error(fmt.Sprintf("Path for %s does not exist: %s", key, path))
+		return path, err
+	}
+
+	// a symlink must not point outside the allowed roots
+	resolved, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return path, err
+	}
+	if mounted != nil && !pathUnder(resolved, resolveRoots([]string{mounted.source})) {
+		return fail(fmt.Sprintf("resolves to %s outside %s", resolved, mounted.source), containerPathRule)
+	}
+	if !pathUnder(resolved, resolveRoots(userRoots)) {
+		return fail(fmt.Sprintf("resolves to %s outside the allowed roots", resolved), userRule)
+	}
+	if len(forceRoots) > 0 && !pathUnder(resolved, resolveRoots(forceRoots)) {
+		return fail(fmt.Sprintf("resolves to %s outside the allowed roots", resolved), forceRule)
+	}
+
+	if mounted != nil && (mounted.target != "." || filepath.IsAbs(path)) {
+		rel, err := filepath.Rel(mounted.source, absPath)
+		if err != nil {
+			return path, err
+		}
+		if mounted.target == "." {
+			path = "./" + rel
+		} else {
+			path = filepath.Join(mounted.target, rel)
+		}
+	}
+
+	return path, nil
+}
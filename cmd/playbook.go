@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"os"
-	"os/exec"
 	"strings"
 )
 
@@ -52,27 +50,95 @@ func (c *PlaybookConfig) RunAnsiblePlaybook() (int, error) {
 
 	// container image was specified
 	if c.Image != "" {
-		rc, _, err = executeCommandInContainer(*c, "/bin/ansible-tui", []string{}, false, "")
-		slog.Info(fmt.Sprintf("Finished executePlaybookInContainer: rc=%d", rc))
+		if err := c.ensureImage(); err != nil {
+			return 1, err
+		}
+		if c.containerHasAnsibleTui() {
+			// the rest of the run summary is printed by ansible-tui in the container
+			fmt.Println(c.imageSummary())
+			rc, _, err = executeCommandInContainer(*c, ansibleTuiContainerPath, fixedArgs(), c.PlaybookTimeout, false, "")
+			slog.Info(fmt.Sprintf("Finished executePlaybookInContainer: rc=%d, image=%s", rc, c.Metrics.ImageDigest))
+			return rc, err
+		}
+
+		// ansible-playbook is run directly in the image (ex. an execution environment without ansible-tui)
+		err = c.VerifyInventory()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Exiting due to inventory file validation error: %s", err))
+			return 1, err
+		}
+		fmt.Println(c.forcedRun().runSummary("ansible-playbook (" + c.Image + ")"))
+		rc, _, err = executeCommandInContainer(*c, "ansible-playbook", func(cc *PlaybookConfig, env map[string]string) []string {
+			cc.ansiblePlaybookEnv(env)
+			return cc.ansiblePlaybookArgs()
+		}, c.PlaybookTimeout, false, "")
+		slog.Info(fmt.Sprintf("Finished running ansible-playbook in container: rc=%d, image=%s", rc, c.Metrics.ImageDigest))
 		return rc, err
 	}
 
 	ansibleCmdPath := "ansible-playbook"
 
+	env := c.commandEnv()
 	if c.VirtualEnvPath != "" {
-		os.Setenv("PATH", c.VirtualEnvPath+"/bin:"+os.Getenv("PATH"))
 		slog.Info("Using virtualenv for " + ansibleCmdPath)
 	}
 
-	path, err := exec.LookPath(ansibleCmdPath)
+	path, err := lookPath(ansibleCmdPath, env)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("%s lookup err: %s", ansibleCmdPath, err))
-		slog.Info(os.Getenv("PATH"))
+		slog.Info(env["PATH"])
 		return 1, err
 	} else {
 		slog.Info(fmt.Sprintf("%s lookup path: %s", ansibleCmdPath, path))
 	}
 
+	run := c.forcedRun()
+	run.ansiblePlaybookEnv(env)
+
+	err = c.validateAnsibleInventory()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to inventory file validation error: %s", err))
+		return 1, err
+	}
+
+	// run ansible version
+
+	// if requirements.yml exists, run ansible-galaxy install
+	// slog.Debug("setup Ansible roles with ansible-galaxy")
+	// ansible-galaxy install -r ./roles/requirements.yml
+	// ansible-galaxy install -r ./playbooks/roles/requirements.yml
+
+	ansiblePlaybookArgs := run.ansiblePlaybookArgs()
+
+	fmt.Println(run.runSummary(path))
+
+	// return RunBufferedCommandWithoutCapture(ansibleCmdPath, ansiblePlaybookArgs, c.PlaybookTimeout)
+	rc, _, err = RunBufferedCommand(path, ansiblePlaybookArgs, envList(env), c.PlaybookTimeout, false, "")
+	return rc, err
+
+}
+
+// Environment variables for ansible-playbook in addition to commandEnv
+func (c *PlaybookConfig) ansiblePlaybookEnv(env map[string]string) {
+
+	// set SSH key or else ssh client defaults will be used if SSH is called
+	if c.SshPrivateKeyFile != "" {
+		env["ANSIBLE_PRIVATE_KEY_FILE"] = c.SshPrivateKeyFile
+	}
+
+	if c.RemoteUser != "" {
+		env["ANSIBLE_REMOTE_USER"] = c.RemoteUser
+	}
+
+	// otherwise the color get's lost in Go's tty/command (unless set in environment-variables.set)
+	if env["ANSIBLE_FORCE_COLOR"] == "" {
+		env["ANSIBLE_FORCE_COLOR"] = "True"
+	}
+}
+
+// Arguments of the ansible-playbook command
+func (c *PlaybookConfig) ansiblePlaybookArgs() []string {
+
 	var ansiblePlaybookArgs []string
 
 	// handle verbose level
@@ -87,35 +153,22 @@ func (c *PlaybookConfig) RunAnsiblePlaybook() (int, error) {
 		ansiblePlaybookArgs = append(ansiblePlaybookArgs, verboseLevel)
 	}
 
-	// set SSH key or else ssh client defaults will be used if SSH is called
-	if c.SshPrivateKeyFile != "" {
-		os.Setenv("ANSIBLE_PRIVATE_KEY_FILE", c.SshPrivateKeyFile)
-	}
+	ansiblePlaybookArgs = append(ansiblePlaybookArgs, "-i", c.InventoryFile, c.Playbook)
 
-	if c.RemoteUser != "" {
-		os.Setenv("ANSIBLE_REMOTE_USER", c.RemoteUser)
+	if c.LimitHost != "" {
+		ansiblePlaybookArgs = append(ansiblePlaybookArgs, "--limit", c.LimitHost)
 	}
 
-	// otherwise the color get's lost in Go's tty/command
-	os.Setenv("ANSIBLE_FORCE_COLOR", "True")
-
-	err = c.validateAnsibleInventory()
-	if err != nil {
-		slog.Error(fmt.Sprintf("Exiting due to inventory file validation error: %s", err))
-		return 1, err
+	if c.AnsibleTags != "" {
+		ansiblePlaybookArgs = append(ansiblePlaybookArgs, "--tags", c.AnsibleTags)
 	}
 
-	// run ansible version
-
-	// if requirements.yml exists, run ansible-galaxy install
-	// slog.Debug("setup Ansible roles with ansible-galaxy")
-	// ansible-galaxy install -r ./roles/requirements.yml
-	// ansible-galaxy install -r ./playbooks/roles/requirements.yml
-
-	ansiblePlaybookArgs = append(ansiblePlaybookArgs, "-i", c.InventoryFile, c.Playbook)
+	if c.AnsibleSkipTags != "" {
+		ansiblePlaybookArgs = append(ansiblePlaybookArgs, "--skip-tags", c.AnsibleSkipTags)
+	}
 
-	if c.LimitHost != "" {
-		ansiblePlaybookArgs = append(ansiblePlaybookArgs, "--limit", c.LimitHost)
+	if c.ExtraVarsFile != "" {
+		ansiblePlaybookArgs = append(ansiblePlaybookArgs, "--extra-vars", "@"+c.ExtraVarsFile)
 	}
 
 	if c.ExtraArgs != "" {
@@ -125,8 +178,5 @@ func (c *PlaybookConfig) RunAnsiblePlaybook() (int, error) {
 		ansiblePlaybookArgs = append(ansiblePlaybookArgs, s...)
 	}
 
-	// return RunBufferedCommandWithoutCapture(ansibleCmdPath, ansiblePlaybookArgs, c.PlaybookTimeout)
-	rc, _, err = RunBufferedCommand(ansibleCmdPath, ansiblePlaybookArgs, c.PlaybookTimeout, false, "")
-	return rc, err
-
+	return ansiblePlaybookArgs
 }
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// Apply the active profile (c.Profile) from the profiles map in the config file on top of the
+// base configuration.  Only keys defined in the profile override base values.
+func (c *PlaybookConfig) applyProfile() error {
+
+	if c.Profile == "" {
+		return nil
+	}
+
+	node, ok := c.Profiles[c.Profile]
+	if !ok {
+		return &InputError{
+			Err: fmt.Errorf("profile not found in config file: %s", c.Profile),
+		}
+	}
+
+	slog.Debug(fmt.Sprintf("Applying profile: %s", c.Profile))
+	err := node.Decode(c)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error unmarshalling profile %s: %s", c.Profile, err))
+		return err
+	}
+	c.recordNodeSources(&node, fmt.Sprintf("%s (%s)", SourceProfile, c.Profile))
+
+	return nil
+}
+
+// ProfileNames returns the sorted names of profiles defined in the config file.
+func (c *PlaybookConfig) ProfileNames() []string {
+	var names []string
+	for k := range c.Profiles {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	redactedValue string = "********"
+
+	// Values shorter than this are not redacted in log output (they would mask unrelated text)
+	redactMinLength int = 4
+
+	// Values of secret environment variables that are masked in log output
+	secretValues   = make(map[string]bool)
+	secretValuesMu sync.Mutex
+)
+
+// IsSecretEnv returns true if the value of an environment variable is a secret.  Names matching
+// regExpSensitiveKey (PASSWORD, TOKEN, etc.) that don't hold a path, or listed in environment-variables.secret are secrets.
+func (c *PlaybookConfig) IsSecretEnv(name string) bool {
+	if regExpSensitiveKey.MatchString(name) && !regExpPathKey.MatchString(name) {
+		return true
+	}
+	return slices.Contains(c.EnvironmentVariables.Secret, name)
+}
+
+// Only values of environment variables can hold secrets (ex. environment-variables.set.VAULT_TOKEN).
+func (c *PlaybookConfig) isSensitiveKey(key string) bool {
+	name, ok := strings.CutPrefix(key, "environment-variables.set.")
+	return ok && c.IsSecretEnv(name)
+}
+
+// Value of an environment variable for log output
+func (c *PlaybookConfig) redactEnv(name string, value string) string {
+	if value != "" && c.IsSecretEnv(name) {
+		return redactedValue
+	}
+	return value
+}
+
+// Register the values of secret environment variables (set, forced and passed) so they are masked in log output.
+func (c *PlaybookConfig) registerSecrets() {
+	env := c.commandEnv()
+
+	secretValuesMu.Lock()
+	defer secretValuesMu.Unlock()
+
+	for k, v := range env {
+		if c.IsSecretEnv(k) && len(v) >= redactMinLength {
+			secretValues[v] = true
+		}
+	}
+}
+
+// Mask the values of secret environment variables in s (ex. a command line or output written to the log).
+func redact(s string) string {
+	secretValuesMu.Lock()
+	defer secretValuesMu.Unlock()
+
+	if len(secretValues) == 0 {
+		return s
+	}
+
+	// longest first so a secret containing another secret is masked completely
+	values := make([]string, 0, len(secretValues))
+	for v := range secretValues {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	for _, v := range values {
+		s = strings.ReplaceAll(s, v, redactedValue)
+	}
+	return s
+}
+
+// WritableEnvs returns the environment-variables.set values that can be written to a config file.
+// Secret values are only written as the ${VAR} reference they were read from.
+func (c *PlaybookConfig) WritableEnvs() map[string]string {
+	envs := make(map[string]string, len(c.EnvironmentVariables.Set))
+	for k, v := range c.EnvironmentVariables.Set {
+		if v != "" && c.IsSecretEnv(k) {
+			if t, ok := c.templates["environment-variables.set."+k]; !ok || t.value != v {
+				slog.Warn(fmt.Sprintf("Not writing secret env %s to config file (use a ${VAR} reference)", k))
+				continue
+			}
+		}
+		envs[k] = v
+	}
+	return envs
+}
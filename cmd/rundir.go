@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var (
+	runDirPrefix string = "run-"
+
+	// location of the run directory inside a container
+	containerRunDir string = "/run/ansible-tui"
+
+	// run directories created by this process, removed by CleanupRunDirs
+	runDirs   []string
+	runDirsMu sync.Mutex
+)
+
+// Private directory (0700) under temp-dir for files written from INVENTORY_CONTENTS, EXTRA_VARS_CONTENTS
+// and SSH_PRIVATE_KEY_CONTENTS.  It is created on first use and removed by CleanupRunDirs.
+func (c *PlaybookConfig) runDirPath() (string, error) {
+	if c.RunDir != "" {
+		return c.RunDir, nil
+	}
+
+	dir, err := os.MkdirTemp(c.TempDirPath, runDirPrefix)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Could not create run directory in %s", c.TempDirPath))
+		return "", err
+	}
+
+	runDirsMu.Lock()
+	runDirs = append(runDirs, dir)
+	runDirsMu.Unlock()
+
+	slog.Debug(fmt.Sprintf("Created run directory: %s", dir))
+	c.RunDir = dir
+	return dir, nil
+}
+
+// Write contents supplied by an environment variable to a file (0600) in the run directory.
+func (c *PlaybookConfig) writeRunFile(name string, contents string) (string, error) {
+	dir, err := c.runDirPath()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	err = WriteFileFromString(path, contents, 0600)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// True if path is a file in the run directory (written by ansible-tui, not subject to the path policy).
+func (c *PlaybookConfig) inRunDir(path string) bool {
+	if c.RunDir == "" {
+		return false
+	}
+	return filepath.Dir(filepath.Clean(path)) == filepath.Clean(c.RunDir)
+}
+
+// Path of a file in the run directory inside a container (see executeCommandInContainer)
+func (c *PlaybookConfig) containerRunPath(path string) string {
+	if !c.inRunDir(path) {
+		return path
+	}
+	return containerRunDir + "/" + filepath.Base(path)
+}
+
+// CleanupRunDirs removes the run directories created by this process.
+func CleanupRunDirs() {
+	runDirsMu.Lock()
+	defer runDirsMu.Unlock()
+
+	for _, dir := range runDirs {
+		if !strings.Contains(filepath.Base(dir), runDirPrefix) {
+			continue
+		}
+		slog.Debug(fmt.Sprintf("Removing run directory: %s", dir))
+		err := os.RemoveAll(dir)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Could not remove run directory %s: %s", dir, err))
+		}
+	}
+	runDirs = nil
+}
+
+// Exit removes the run directories and exits with code.
+func Exit(code int) {
+	CleanupRunDirs()
+	os.Exit(code)
+}
+
+// HandleSignals removes the run directories when ansible-tui is interrupted or terminated.
+func HandleSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		sig := <-ch
+		slog.Warn(fmt.Sprintf("Received %s, exiting", sig))
+		Exit(128 + int(sig.(syscall.Signal)))
+	}()
+}
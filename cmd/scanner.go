@@ -0,0 +1,469 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Directories that never contain playbooks or inventory for the project being scanned.
+	scanIgnoreDirs = map[string]bool{
+		".git":                true,
+		".hg":                 true,
+		".svn":                true,
+		".tox":                true,
+		".venv":               true,
+		"venv":                true,
+		".cache":              true,
+		".ansible-tui":        true,
+		"node_modules":        true,
+		"__pycache__":         true,
+		"ansible_collections": true,
+	}
+
+	// limit concurrent directory reads and file parsing
+	scanWorkers = runtime.NumCPU() * 4
+)
+
+// Options for a single Scanner.Scan call.
+// Include and Exclude are gitignore style globs (ex. "*.yml", "playbooks/**", "old/").
+type ScanOptions struct {
+	Exts    []string
+	Recurse bool
+	Include []string
+	Exclude []string
+}
+
+// Scanner lists project files concurrently and caches results between calls.
+// Scan results are reused until the modification time of one of the scanned directories changes.
+// File detection results (inventory and playbook) are reused until the file's size or modification time changes.
+type Scanner struct {
+	mu        sync.Mutex
+	scans     map[string]scanResult
+	detected  map[string]detectResult
+	playbooks map[string]detectResult
+}
+
+type scanResult struct {
+	files []string
+	dirs  map[string]time.Time
+}
+
+type detectResult struct {
+	modTime   time.Time
+	size      int64
+	inventory InventoryInfo
+	playbook  *PlaybookInfo
+}
+
+type ignoreRule struct {
+	regexp  *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+func NewScanner() *Scanner {
+	return &Scanner{
+		scans:     make(map[string]scanResult),
+		detected:  make(map[string]detectResult),
+		playbooks: make(map[string]detectResult),
+	}
+}
+
+// Clear removes all cached scan and detection results.
+func (s *Scanner) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.scans)
+	clear(s.detected)
+	clear(s.playbooks)
+}
+
+// Scan returns files under root with one of the extensions in opts.Exts ("" matches files without
+// an extension).  Paths are formatted like ListDir (relative paths are prefixed with "./").
+func (s *Scanner) Scan(root string, opts ScanOptions) ([]string, error) {
+
+	key := fmt.Sprintf("%s|%v|%t|%v|%v", root, opts.Exts, opts.Recurse, opts.Include, opts.Exclude)
+
+	s.mu.Lock()
+	cached, ok := s.scans[key]
+	s.mu.Unlock()
+	if ok && !scanDirsChanged(cached.dirs) {
+		slog.Debug(fmt.Sprintf("Using cached scan results for directory: %s", root))
+		return cached.files, nil
+	}
+
+	slog.Debug(fmt.Sprintf("Scanning directory: %s", root))
+	result, err := scanDir(root, opts)
+	if err != nil {
+		return result.files, err
+	}
+
+	s.mu.Lock()
+	s.scans[key] = result
+	s.mu.Unlock()
+
+	return result.files, nil
+}
+
+func scanDirsChanged(dirs map[string]time.Time) bool {
+	for dir, modTime := range dirs {
+		stat, err := os.Stat(dir)
+		if err != nil || !stat.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func scanDir(root string, opts ScanOptions) (scanResult, error) {
+
+	result := scanResult{dirs: make(map[string]time.Time)}
+
+	stat, err := os.Stat(root)
+	if err != nil {
+		return result, err
+	}
+	if !stat.IsDir() {
+		return result, &InputError{
+			Err: fmt.Errorf("path must be a directory: %s", root),
+		}
+	}
+
+	include := compileGlobs(opts.Include)
+	exclude := compileGlobs(opts.Exclude)
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, scanWorkers)
+	)
+
+	var visit func(dir string, rules []ignoreRule)
+	visit = func(dir string, rules []ignoreRule) {
+		defer wg.Done()
+
+		sem <- struct{}{}
+		dirStat, statErr := os.Stat(dir)
+		entries, err := os.ReadDir(dir)
+		<-sem
+		if err != nil || statErr != nil {
+			// unreadable directories are skipped instead of failing the whole scan
+			slog.Debug(fmt.Sprintf("Skipping unreadable directory: %s, %v", dir, err))
+			return
+		}
+
+		mu.Lock()
+		result.dirs[dir] = dirStat.ModTime()
+		mu.Unlock()
+
+		if gitignore, err := readGitignore(dir); err == nil {
+			rules = append(rules[:len(rules):len(rules)], gitignore...)
+		}
+
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			rel := scanRelPath(root, path)
+
+			if e.IsDir() {
+				if !opts.Recurse || scanIgnoreDirs[e.Name()] || isIgnored(rules, path, true) || isVirtualEnv(path) {
+					continue
+				}
+				if len(exclude) > 0 && matchGlobs(exclude, rel, true) {
+					continue
+				}
+				wg.Add(1)
+				go visit(path, rules)
+				continue
+			}
+
+			if !matchExt(e.Name(), opts.Exts) || isIgnored(rules, path, false) {
+				continue
+			}
+			if len(include) > 0 && !matchGlobs(include, rel, false) {
+				continue
+			}
+			if len(exclude) > 0 && matchGlobs(exclude, rel, false) {
+				continue
+			}
+
+			mu.Lock()
+			result.files = append(result.files, formatScanPath(root, path))
+			mu.Unlock()
+		}
+	}
+
+	// .gitignore in the current directory (project root) also applies when scanning a subdirectory
+	var rules []ignoreRule
+	if !filepath.IsAbs(root) && filepath.Clean(root) != "." {
+		rules, _ = readGitignore(".")
+	}
+
+	wg.Add(1)
+	go visit(root, rules)
+	wg.Wait()
+
+	sort.Strings(result.files)
+	return result, nil
+}
+
+// Format paths the same way as ListDir (relative roots are prefixed with "./").
+func formatScanPath(root string, path string) string {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "./") {
+		return path
+	}
+	if strings.HasPrefix(root, "./") || root == "." {
+		return "./" + path
+	}
+	return path
+}
+
+// Globs are matched against paths relative to the current directory (project root),
+// or relative to root when root is an absolute path.
+func scanRelPath(root string, path string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+func matchExt(name string, exts []string) bool {
+	if len(exts) == 0 {
+		return true
+	}
+	for _, s := range exts {
+		if s == "" && !strings.Contains(name, ".") {
+			return true
+		}
+		if s != "" && strings.HasSuffix(name, "."+s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Python virtual environments are detected by pyvenv.cfg regardless of their directory name.
+func isVirtualEnv(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "pyvenv.cfg"))
+	return err == nil
+}
+
+func readGitignore(dir string) ([]ignoreRule, error) {
+
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// patterns with a slash are relative to the directory of the .gitignore file
+		pattern := line
+		if strings.Contains(strings.TrimPrefix(line, "/"), "/") || strings.HasPrefix(line, "/") {
+			pattern = filepath.ToSlash(filepath.Join(dir, strings.TrimPrefix(line, "/")))
+		}
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("Skipping invalid .gitignore pattern in %s: %s", dir, line))
+			continue
+		}
+		rule.regexp = re
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// Later rules take precedence over earlier rules (negated rules re-include paths).
+func isIgnored(rules []ignoreRule, path string, isDir bool) bool {
+	ignored := false
+	path = filepath.ToSlash(filepath.Clean(path))
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.regexp.MatchString(path) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func compileGlobs(globs []string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, g := range globs {
+		re, err := globToRegexp(strings.TrimSuffix(strings.TrimPrefix(g, "./"), "/"))
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Skipping invalid glob pattern: %s", g))
+			continue
+		}
+		res = append(res, re)
+	}
+	return res
+}
+
+// A directory matches a glob if the glob matches the directory itself or the glob is a prefix
+// for files below it (ex. "playbooks/**").
+func matchGlobs(globs []*regexp.Regexp, rel string, isDir bool) bool {
+	for _, re := range globs {
+		if re.MatchString(rel) {
+			return true
+		}
+		if isDir && re.MatchString(rel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert a gitignore style glob to a regular expression.  Patterns without a slash match the
+// base name at any depth.  "**" matches any number of directories, "*" and "?" do not match "/".
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+
+	var b strings.Builder
+	if !strings.Contains(glob, "/") {
+		b.WriteString(`^(.*/)?`)
+	} else {
+		b.WriteString(`^`)
+	}
+
+	for i := 0; i < len(glob); i++ {
+		ch := glob[i]
+		switch ch {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString(`(.*/)?`)
+				} else {
+					b.WriteString(`.*`)
+				}
+			} else {
+				b.WriteString(`[^/]*`)
+			}
+		case '?':
+			b.WriteString(`[^/]`)
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+
+	// a matching directory also matches everything below it
+	b.WriteString(`(/.*)?$`)
+	return regexp.Compile(b.String())
+}
+
+// DetectInventories runs DetectInventory concurrently for files and caches the results.
+func (s *Scanner) DetectInventories(files []string) map[string]InventoryInfo {
+	results := make(map[string]InventoryInfo)
+	s.detectAll(files, s.detected, func(file string, r *detectResult) error {
+		info, err := DetectInventory(file)
+		r.inventory = info
+		return err
+	})
+	s.mu.Lock()
+	for _, file := range files {
+		results[file] = s.detected[file].inventory
+	}
+	s.mu.Unlock()
+	return results
+}
+
+// IndexPlaybooks runs DetectPlaybook concurrently for files and caches the results.
+func (s *Scanner) IndexPlaybooks(files []string) []PlaybookInfo {
+	var index []PlaybookInfo
+	s.detectAll(files, s.playbooks, func(file string, r *detectResult) error {
+		info, err := DetectPlaybook(file)
+		r.playbook = info
+		return err
+	})
+	s.mu.Lock()
+	for _, file := range files {
+		if pb := s.playbooks[file].playbook; pb != nil {
+			index = append(index, *pb)
+		}
+	}
+	s.mu.Unlock()
+	return index
+}
+
+func (s *Scanner) detectAll(files []string, cache map[string]detectResult, detect func(string, *detectResult) error) {
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, scanWorkers)
+	)
+
+	for _, file := range files {
+		stat, err := os.Stat(file)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("Skipping file that could not be read: %s, %s", file, err))
+			continue
+		}
+
+		s.mu.Lock()
+		cached, ok := cache[file]
+		s.mu.Unlock()
+		if ok && cached.size == stat.Size() && cached.modTime.Equal(stat.ModTime()) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(file string, stat os.FileInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			r := detectResult{modTime: stat.ModTime(), size: stat.Size()}
+			if err := detect(file, &r); err != nil {
+				slog.Debug(fmt.Sprintf("Could not inspect file: %s, %s", file, err))
+			}
+			s.mu.Lock()
+			cache[file] = r
+			s.mu.Unlock()
+		}(file, stat)
+	}
+
+	wg.Wait()
+}
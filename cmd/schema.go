@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error for a single key or value in a config file.  Line and Column are 1-based.
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+var (
+	// PlaybookConfig keys that are internal state.  These are only accepted in the config generated for
+	// ansible-tui in a container (see isContainerConfig) and are not published in the JSON Schema.
+	internalConfigKeys = map[string]bool{
+		"metrics":        true,
+		"configfilepath": true,
+		"lintenabled":    true,
+		"lintconfigfile": true,
+		"rundir":         true,
+	}
+
+	// Additional JSON Schema keywords by dotted key
+	schemaKeywords = map[string]map[string]interface{}{
+		"verbose-level":  {"minimum": 0, "maximum": 7},
+		"execution-type": {"enum": []string{"container", "venv"}},
+		"pull-policy":    {"enum": []string{"always", "missing", "never"}},
+		"ssh-agent":      {"enum": []string{"auto", "always", "never"}},
+	}
+
+	yamlNodeType       = reflect.TypeOf(yaml.Node{})
+	playbookConfigType = reflect.TypeOf(PlaybookConfig{})
+	globalConfigType   = reflect.TypeOf(GlobalConfig{})
+
+	// yaml.Node fields that are decoded onto PlaybookConfig later (profiles and global defaults)
+	nodeFieldTypes = map[reflect.Type]map[string]reflect.Type{
+		playbookConfigType: {"profiles": reflect.MapOf(reflect.TypeOf(""), playbookConfigType)},
+		globalConfigType:   {"default": playbookConfigType},
+	}
+)
+
+// YAML key for a struct field or "" if the field is not read from YAML.
+func yamlFieldName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		if name := yamlFieldName(t.Field(i)); name != "" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+	return fields
+}
+
+// ValidateConfigFile checks a config file for unknown keys and values with the wrong type.
+// The returned InputError lists every problem with its line and column.
+func ValidateConfigFile(file string) error {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	_, err = NewPlaybookConfig().parseConfFile(file, buf)
+	return err
+}
+
+// True if file is the config written by executeCommandInContainer for ansible-tui in the container
+func isContainerConfig(file string) bool {
+	return file != "" && file == os.Getenv(containerConfigEnvVar)
+}
+
+func validateConfig(file string, buf []byte, t reflect.Type) error {
+
+	node := yaml.Node{}
+	err := yaml.Unmarshal(buf, &node)
+	if err != nil {
+		return &InputError{
+			Err: fmt.Errorf("%s: %w", file, err),
+		}
+	}
+	return validateConfigDocument(file, &node, t)
+}
+
+func validateConfigDocument(file string, node *yaml.Node, t reflect.Type) error {
+
+	if len(node.Content) == 0 {
+		return nil
+	}
+
+	var errs []error
+	validateConfigNode(file, node.Content[0], t, "", &errs)
+	if len(errs) > 0 {
+		return &InputError{
+			Err: errors.Join(errs...),
+		}
+	}
+	return nil
+}
+
+func validateConfigNode(file string, node *yaml.Node, t reflect.Type, key string, errs *[]error) {
+
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+		return
+	}
+
+	fail := func(n *yaml.Node, format string, a ...interface{}) {
+		*errs = append(*errs, &ConfigError{File: file, Line: n.Line, Column: n.Column, Msg: fmt.Sprintf(format, a...)})
+	}
+	wrongType := func(want string) {
+		got := node.Value
+		if node.Kind != yaml.ScalarNode {
+			got = map[yaml.Kind]string{yaml.MappingNode: "a mapping", yaml.SequenceNode: "a list"}[node.Kind]
+		} else {
+			got = fmt.Sprintf("%q", got)
+		}
+		name := key
+		if name == "" {
+			name = "config file"
+		}
+		fail(node, "%s must be %s, got %s", name, want, got)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == yamlNodeType {
+			return
+		}
+		if node.Kind != yaml.MappingNode {
+			wrongType("a mapping")
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			childKey := k.Value
+			if key != "" {
+				childKey = key + "." + k.Value
+			}
+			ft, ok := fields[k.Value]
+			if t == playbookConfigType && internalConfigKeys[k.Value] && !isContainerConfig(file) {
+				ok = false
+			}
+			if !ok {
+				msg := fmt.Sprintf("unknown key %s", childKey)
+				if s := suggestKey(k.Value, fields); s != "" {
+					msg = fmt.Sprintf("%s (did you mean %s?)", msg, s)
+				}
+				fail(k, "%s", msg)
+				continue
+			}
+			if nt, ok := nodeFieldTypes[t][k.Value]; ok {
+				ft = nt
+			}
+			validateConfigNode(file, v, ft, childKey, errs)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			wrongType("a mapping")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			validateConfigNode(file, node.Content[i+1], t.Elem(), key+"."+node.Content[i].Value, errs)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			wrongType("a list")
+			return
+		}
+		for i, item := range node.Content {
+			validateConfigNode(file, item, t.Elem(), fmt.Sprintf("%s[%d]", key, i), errs)
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			wrongType("a string")
+		}
+	case reflect.Int:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!int" {
+			wrongType("an integer")
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!bool" {
+			wrongType("true or false")
+		}
+	}
+}
+
+// Suggest a known key for a misspelled key (ex. inventroy -> inventory, verbose_level -> verbose-level).
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if internalConfigKeys[name] {
+			continue
+		}
+		if strings.ReplaceAll(key, "_", "-") == name {
+			return name
+		}
+		if d := editDistance(key, name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// ConfigJSONSchema returns a JSON Schema for the config file format (for editor validation and autocompletion).
+func ConfigJSONSchema() ([]byte, error) {
+	schema := jsonSchemaFor(playbookConfigType, "")
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "ansible-tui configuration"
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func jsonSchemaFor(t reflect.Type, key string) map[string]interface{} {
+
+	schema := map[string]interface{}{}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			name := yamlFieldName(t.Field(i))
+			if name == "" || (key == "" && internalConfigKeys[name]) {
+				continue
+			}
+			childKey := name
+			if key != "" {
+				childKey = key + "." + name
+			}
+			if t == playbookConfigType && name == "profiles" {
+				properties[name] = map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": map[string]interface{}{"$ref": "#"},
+				}
+				continue
+			}
+			properties[name] = jsonSchemaFor(t.Field(i).Type, childKey)
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = jsonSchemaFor(t.Elem(), key)
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = jsonSchemaFor(t.Elem(), key)
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Int:
+		schema["type"] = "integer"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	}
+
+	for k, v := range schemaKeywords[key] {
+		schema[k] = v
+	}
+	return schema
+}
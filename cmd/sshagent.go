@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Values of ssh-agent
+const (
+	SshAgentAuto   = "auto" // default, use the agent when no ssh-private-key-file is set
+	SshAgentAlways = "always"
+	SshAgentNever  = "never"
+)
+
+var (
+	// location of the forwarded agent socket inside a container
+	containerSshAgentSock string = "/run/ssh-agent.sock"
+)
+
+// Socket of the SSH agent (SSH_AUTH_SOCK) if it is available
+func sshAgentSocket() (string, bool) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return "", false
+	}
+	stat, err := os.Stat(sock)
+	if err != nil || stat.Mode()&os.ModeSocket == 0 {
+		slog.Debug(fmt.Sprintf("SSH_AUTH_SOCK is not a socket: %s", sock))
+		return "", false
+	}
+	return sock, true
+}
+
+// Decide whether the SSH agent is used for the run (see ssh-agent).  Called by ValidateInputs after
+// ssh-private-key-file is checked.
+func (c *PlaybookConfig) resolveSshAgent() error {
+
+	c.sshAgentSock = ""
+	sock, ok := sshAgentSocket()
+
+	switch c.SshAgent {
+	case SshAgentNever:
+		return nil
+	case SshAgentAlways:
+		if !ok {
+			return &InputError{
+				Err: errors.New("ssh-agent is always, but no SSH agent was found (SSH_AUTH_SOCK)"),
+			}
+		}
+	case "", SshAgentAuto:
+		if c.SshPrivateKeyFile != "" {
+			return nil
+		}
+		if !ok {
+			slog.Warn("No ssh-private-key-file is set and no SSH agent was found (SSH_AUTH_SOCK)")
+			return nil
+		}
+	default:
+		return &InputError{
+			Err: fmt.Errorf("ssh-agent must be auto, always or never: %s", c.SshAgent),
+		}
+	}
+
+	slog.Info(fmt.Sprintf("Using SSH agent: %s", sock))
+	c.sshAgentSock = sock
+	return nil
+}
+
+// True if the SSH agent is used for the run (see resolveSshAgent)
+func (c *PlaybookConfig) UsesSshAgent() bool {
+	return c.sshAgentSock != ""
+}
@@ -0,0 +1,682 @@
+package main
+
+import (
+	"a5e/cmd"
+	"a5e/tui"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Exit codes for all subcommands.  run and lint exit with the return code of
+// ansible-playbook and ansible-lint when the command was started.
+const (
+	exitOK    = 0
+	exitError = 1 // configuration, validation or execution error
+	exitUsage = 2 // unknown subcommand, flag or argument
+)
+
+// How the project config file is chosen when -c and PB_CONFIG_FILE are not set.
+const (
+	configNone     = iota // no project config file (environment variables and flags only)
+	configIfExists        // default config file if it exists
+	configGenerate        // default config file, generated if it doesn't exist
+)
+
+type subcommand struct {
+	name    string
+	args    string // positional arguments shown in usage (ex. "[playbook|all]")
+	summary string
+	help    string
+	flags   func(o *cliOptions) // subcommand specific flags
+	run     func(o *cliOptions, args []string) int
+}
+
+// Options shared by all subcommands.
+type cliOptions struct {
+	fs            *flag.FlagSet
+	configFile    string
+	profile       string
+	logLevel1     bool
+	logLevel2     bool
+	clearCache    bool
+	force         bool
+	historyCount  int
+	paramFlagKeys map[string]string
+}
+
+var subcommands []subcommand
+
+func init() {
+	subcommands = []subcommand{
+		{
+			name:    "tui",
+			summary: "Start the TUI (default)",
+			help:    "Starts the TUI.  The default config file (./.ansible-tui/config.yml) is generated if -c is not passed and it doesn't exist.",
+			run:     runTui,
+		},
+		{
+			name:    "run",
+			summary: "Run the playbook without the TUI",
+			help:    "Runs ansible-playbook with the configuration from PB_CONFIG_FILE (-c), environment variables and flags.\nExits with the ansible-playbook return code.",
+			run:     runPlaybook,
+		},
+		{
+			name:    "lint",
+			args:    "[playbook|all]",
+			summary: "Run ansible-lint against the playbook or all files",
+			help:    "Runs ansible-lint against the configured playbook (default) or all files in the current directory.\nExits with the ansible-lint return code.",
+			run:     runLint,
+		},
+		{
+			name:    "inventory",
+			args:    "graph|list|verify",
+			summary: "Show, find or verify inventories",
+			help:    "graph   Display the ansible-inventory --graph output for the configured inventory\nlist    List inventory files in tui.inventory-dir with the detected inventory type\nverify  Exit 1 if ansible-inventory can't parse the configured inventory",
+			run:     runInventory,
+		},
+		{
+			name:    "config",
+			args:    "init|show|validate|schema",
+			summary: "Create, display or validate the configuration",
+			help:    "init      Generate a config file template (-c or ./.ansible-tui/config.yml)\nshow      Display each effective configuration value and where it was set\nvalidate  Exit 1 if a config file has unknown keys or values with the wrong type,\n          or the effective configuration is not valid for running a playbook\nschema    Display the JSON Schema for config files",
+			flags: func(o *cliOptions) {
+				o.fs.BoolVar(&o.force, "force", false, "Overwrite an existing config file (init)")
+			},
+			run: runConfig,
+		},
+		{
+			name:    "history",
+			args:    "[list|clear]",
+			summary: "List or clear recent playbook runs",
+			help:    "list   List recent playbook runs (default) with the return code, duration, inventory and image digest\nclear  Remove the recorded playbook runs",
+			flags: func(o *cliOptions) {
+				o.fs.IntVar(&o.historyCount, "n", 20, "Number of runs to list, 0 for all (list)")
+			},
+			run: runHistory,
+		},
+		{
+			name:    "images",
+			summary: "List container images",
+			help:    "Lists container images from podman or docker that match tui.image-filter.",
+			run:     runImages,
+		},
+		{
+			name:    "version",
+			summary: "Display version and exit",
+			help:    "Displays the version and build date.",
+			run:     runVersion,
+		},
+	}
+}
+
+func findSubcommand(name string) *subcommand {
+	for i := range subcommands {
+		if subcommands[i].name == name {
+			return &subcommands[i]
+		}
+	}
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: ansible-tui [command] [flags]\n\nCommands:\n")
+	for _, sc := range subcommands {
+		fmt.Fprintf(w, "  %-34s %s\n", strings.TrimSpace(sc.name+" "+sc.args), sc.summary)
+	}
+	fmt.Fprintf(w, "  %-34s %s\n", "help [command]", "Display help for a command")
+	fmt.Fprintf(w, `
+Flags without a command are aliases: -nt (run), -lp (lint playbook), -la (lint all),
+-g (config init), -explain-config (config show) and -version (version).
+
+Exit codes: 0 success, 1 error, 2 usage error.  run and lint exit with the
+ansible-playbook and ansible-lint return codes.
+
+Run "ansible-tui help <command>" for the flags of a command.
+`)
+}
+
+// Flags shared by all subcommands.  Defaults for -c and -p come from PB_CONFIG_FILE and PB_PROFILE.
+func newCliOptions(name string) *cliOptions {
+	o := &cliOptions{
+		fs:            flag.NewFlagSet(name, flag.ContinueOnError),
+		paramFlagKeys: make(map[string]string),
+	}
+	o.fs.StringVar(&o.configFile, "c", LookupEnvOrString("PB_CONFIG_FILE", ""), "Playbook config file (PB_CONFIG_FILE)")
+	o.fs.StringVar(&o.profile, "p", LookupEnvOrString("PB_PROFILE", ""), "Profile from the profiles section of the config file (PB_PROFILE)")
+	o.fs.BoolVar(&o.logLevel1, "v", false, "Sets log level for ansible-tui to INFO (default WARN)")
+	o.fs.BoolVar(&o.logLevel2, "vv", false, "Sets log level for ansible-tui to DEBUG (default WARN)")
+	o.fs.BoolVar(&o.clearCache, "clear-cache", false, "Clear cached inventory results before running")
+	for _, p := range paramFlags {
+		o.fs.String(p.name, "", p.usage)
+		o.paramFlagKeys[p.name] = p.key
+	}
+	return o
+}
+
+// Parse flags before and after positional arguments (ex. "lint all -c config.yml").
+func (o *cliOptions) parse(args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := o.fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = o.fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Print an error with the subcommand usage and return the usage exit code.
+func (o *cliOptions) usageError(format string, a ...any) int {
+	fmt.Fprintf(o.fs.Output(), format+"\n", a...)
+	o.fs.Usage()
+	return exitUsage
+}
+
+func (sc *subcommand) execute(args []string) int {
+	o := newCliOptions(sc.name)
+	if sc.flags != nil {
+		sc.flags(o)
+	}
+	o.fs.Usage = func() {
+		w := o.fs.Output()
+		fmt.Fprintf(w, "Usage: ansible-tui %s [flags]\n\n%s\n\nFlags:\n", strings.TrimSpace(sc.name+" "+sc.args), sc.help)
+		o.fs.PrintDefaults()
+	}
+
+	positional, err := o.parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	return sc.run(o, positional)
+}
+
+// runCLI dispatches to a subcommand and returns the exit code.  Without a subcommand,
+// the original flags (-nt, -lp, -la, -g, -explain-config, -version) select the command.
+func runCLI(args []string) int {
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if args[0] == "help" {
+			return runHelp(args[1:])
+		}
+		sc := findSubcommand(args[0])
+		if sc == nil {
+			fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", args[0])
+			usage(os.Stderr)
+			return exitUsage
+		}
+		return sc.execute(args[1:])
+	}
+
+	o := newCliOptions("ansible-tui")
+	displayVersion := o.fs.Bool("version", false, "Display version and exit (version)")
+	generateTemplate := o.fs.Bool("g", false, "Generate ansible-tui.yml template and exit (config init)")
+	noTui := o.fs.Bool("nt", LookupEnvOrBool("NO_TUI", false), "No TUI.  Runs playbook from configuration file without TUI (run)")
+	lintPlaybook := o.fs.Bool("lp", LookupEnvOrBool("LINT_PLAYBOOK", false), "Lint playbook.  Runs ansible-lint against playbook from configuration file without TUI (lint playbook)")
+	lintAll := o.fs.Bool("la", LookupEnvOrBool("LINT_ALL", false), "Lint all.  Runs ansible-lint against all files without TUI (lint all)")
+	explainConfig := o.fs.Bool("explain-config", false, "Display each effective configuration value and where it was set, then exit (config show)")
+	o.fs.Usage = func() {
+		usage(o.fs.Output())
+		fmt.Fprintf(o.fs.Output(), "\nFlags:\n")
+		o.fs.PrintDefaults()
+	}
+
+	err := o.fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if o.fs.NArg() > 0 {
+		return o.usageError("unexpected arguments: %s", strings.Join(o.fs.Args(), " "))
+	}
+
+	switch {
+	case *displayVersion:
+		return runVersion(o, nil)
+	case *generateTemplate:
+		o.force = true
+		return runConfig(o, []string{"init"})
+	case *explainConfig:
+		return runConfig(o, []string{"show"})
+	case *lintAll:
+		return runLint(o, []string{"all"})
+	case *lintPlaybook:
+		return runLint(o, []string{"playbook"})
+	case *noTui:
+		return runPlaybook(o, nil)
+	}
+	return runTui(o, nil)
+}
+
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stdout)
+		return exitOK
+	}
+	sc := findSubcommand(args[0])
+	if sc == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", args[0])
+		usage(os.Stderr)
+		return exitUsage
+	}
+	return sc.execute([]string{"-h"})
+}
+
+// Create a PlaybookConfig from the configuration layers, environment variables and CLI flags.
+// configMode selects the project config file when -c and PB_CONFIG_FILE are not set.
+func (o *cliOptions) loadConfig(configMode int) (*cmd.PlaybookConfig, error) {
+
+	// set log level according to -v / -vv CLI options
+	if o.logLevel1 {
+		slog.SetLogLoggerLevel(slog.LevelInfo)
+	}
+	if o.logLevel2 {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+
+	// create new/empty PlaybookConfig from struct
+	c := cmd.NewPlaybookConfig()
+
+	// handle config file path and temp directory path
+	pbConfigFile := o.configFile
+	if pbConfigFile != "" {
+		c.ConfigFilePath = pbConfigFile
+	} else {
+		c.ConfigFilePath = defaultConfigFilePath
+	}
+	c.TempDirPath = defaultTempPath
+	c.Profile = o.profile
+
+	if pbConfigFile == "" && configMode != configNone {
+		_, err := os.Stat(defaultConfigFilePath)
+		if err == nil {
+			pbConfigFile = defaultConfigFilePath
+		} else if os.IsNotExist(err) && configMode == configGenerate {
+			err = c.GenerateTemplateFile(defaultConfigFilePath)
+			if err != nil {
+				return nil, fmt.Errorf("generating initial configuration file: %w", err)
+			}
+			pbConfigFile = defaultConfigFilePath
+		}
+	}
+
+	// read config file layers (global defaults, user config, project config, profile) into PlaybookConfig struct
+	err := c.LoadConfig(pbConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("config file error: %w", err)
+	}
+
+	// read environment variables into PlaybookConfig struct
+	err = c.ReadEnvs()
+	if err != nil {
+		return nil, fmt.Errorf("reading environment variables: %w", err)
+	}
+
+	// apply CLI flags for playbook parameters (only flags passed on the command line)
+	o.fs.Visit(func(f *flag.Flag) {
+		key, ok := o.paramFlagKeys[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		err = c.SetParam(key, f.Value.String(), fmt.Sprintf("%s (-%s)", cmd.SourceFlag, f.Name))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("CLI flags: %w", err)
+	}
+
+	// invalidate cached inventory results (temp-dir can be changed by TMP_DIR_PATH in ReadEnvs)
+	if o.clearCache {
+		err = c.ClearInventoryCache()
+		if err != nil {
+			return nil, fmt.Errorf("clearing inventory cache: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+func runTui(o *cliOptions, args []string) int {
+	if len(args) > 0 {
+		return o.usageError("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	c, err := o.loadConfig(configGenerate)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to %s", err))
+		return exitError
+	}
+
+	err = c.ValidateTuiParams()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to invalid TUI parameters: %s", err))
+		return exitError
+	}
+
+	slog.Debug("Creating TUI")
+	tui.BuildDate = BuildDate
+	tui.BuildVersion = BuildVersion
+	a, err := tui.NewTUI(c)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to errors starting TUI: %s", err))
+		return exitError
+	}
+
+	slog.Debug("Starting TUI")
+	err = a.Start()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to errors starting TUI: %s", err))
+		return exitError
+	}
+	// This should exit within the TUI to ensure proper exit (below should never run)
+	a.Stop()
+	return exitOK
+}
+
+// Process and validate inputs, then run ansible with fn.  Returns the ansible return code.
+func runAnsible(c *cmd.PlaybookConfig, name string, fn func() (int, error)) int {
+
+	// process values in PlaybookConfig struct
+	err := c.ProcessEnvs()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to errors processing inputs: %s", err))
+		return exitError
+	}
+
+	// validate inputs in PlaybookConfig struct
+	err = c.ValidateInputs()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due validation errors: %s", err))
+		return exitError
+	}
+
+	// Using PlaybookConfig struct, determine runtime environment (ansible in path, in Python venv, or container).
+	// If container execution, write struct to file, run ansible-tui inside container to read and execute ansible.
+	c.Metrics.ExitCode, err = fn()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error running %s: %s", name, err))
+		return exitError
+	}
+	return c.Metrics.ExitCode
+}
+
+func runPlaybook(o *cliOptions, args []string) int {
+	if len(args) > 0 {
+		return o.usageError("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	c, err := o.loadConfig(configNone)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to %s", err))
+		return exitError
+	}
+
+	return runAnsible(c, "playbook", func() (int, error) {
+		start := time.Now()
+		rc, err := c.RunAnsiblePlaybook()
+		if herr := c.RecordRun(start, rc, err); herr != nil {
+			slog.Warn(fmt.Sprintf("Unable to record run in history: %s", herr))
+		}
+		return rc, err
+	})
+}
+
+func runLint(o *cliOptions, args []string) int {
+	target := "playbook"
+	if len(args) > 1 {
+		return o.usageError("unexpected arguments: %s", strings.Join(args[1:], " "))
+	}
+	if len(args) == 1 {
+		target = args[0]
+	}
+	if target != "playbook" && target != "all" {
+		return o.usageError("lint target must be playbook or all: %s", target)
+	}
+
+	c, err := o.loadConfig(configNone)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to %s", err))
+		return exitError
+	}
+	c.LintEnabled = true
+
+	if target == "all" {
+		return runAnsible(c, "ansible-lint (all)", func() (int, error) { return c.RunAnsibleLint(".") })
+	}
+	return runAnsible(c, "ansible-lint (playbook)", func() (int, error) { return c.RunAnsibleLint(c.Playbook) })
+}
+
+func runInventory(o *cliOptions, args []string) int {
+	if len(args) != 1 {
+		return o.usageError("inventory requires one of: graph, list, verify")
+	}
+
+	action := args[0]
+	if action != "graph" && action != "list" && action != "verify" {
+		return o.usageError("unknown inventory command: %s", action)
+	}
+
+	c, err := o.loadConfig(configIfExists)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to %s", err))
+		return exitError
+	}
+
+	if action == "list" {
+		return listInventories(c)
+	}
+
+	err = c.ProcessEnvs()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to errors processing inputs: %s", err))
+		return exitError
+	}
+
+	if action == "verify" {
+		err = c.VerifyInventory()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Inventory verification failed: %s", err))
+			return exitError
+		}
+		fmt.Printf("inventory is valid: %s (%d hosts)\n", c.InventoryFile, c.Metrics.InventoryCount)
+		return exitOK
+	}
+
+	outputLines, err := c.InventoryGraph()
+	if outputLines != nil {
+		fmt.Println(strings.Join(*outputLines, "\n"))
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error running ansible-inventory: %s", err))
+		return exitError
+	}
+	return exitOK
+}
+
+func listInventories(c *cmd.PlaybookConfig) int {
+	inventoryDir := c.Tui.InventoryDir
+	if inventoryDir == "" {
+		inventoryDir = "."
+	}
+
+	scanner := cmd.NewScanner()
+	files, err := scanner.Scan(inventoryDir, cmd.ScanOptions{
+		Exts:    []string{"yaml", "yml", "ini", "txt", ""},
+		Recurse: inventoryDir != ".",
+		Include: c.Tui.Include,
+		Exclude: c.Tui.Exclude,
+	})
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error scanning inventory directory %s: %s", inventoryDir, err))
+		return exitError
+	}
+
+	sort.Strings(files)
+	inventories := scanner.DetectInventories(files)
+	for _, file := range files {
+		if info := inventories[file]; info.Type != cmd.InventoryTypeNone {
+			fmt.Printf("%s\t%s\n", file, info.Label())
+		}
+	}
+	return exitOK
+}
+
+func runHistory(o *cliOptions, args []string) int {
+	action := "list"
+	if len(args) > 1 {
+		return o.usageError("unexpected arguments: %s", strings.Join(args[1:], " "))
+	}
+	if len(args) == 1 {
+		action = args[0]
+	}
+	if action != "list" && action != "clear" {
+		return o.usageError("unknown history command: %s", action)
+	}
+	if o.historyCount < 0 {
+		return o.usageError("-n must not be negative: %d", o.historyCount)
+	}
+
+	c, err := o.loadConfig(configIfExists)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to %s", err))
+		return exitError
+	}
+
+	if action == "clear" {
+		err = c.ClearHistory()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Exiting due to error clearing history: %s", err))
+			return exitError
+		}
+		return exitOK
+	}
+
+	entries, err := c.ReadHistory()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to error reading history: %s", err))
+		return exitError
+	}
+	if o.historyCount > 0 && len(entries) > o.historyCount {
+		entries = entries[len(entries)-o.historyCount:]
+	}
+	for _, e := range entries {
+		fmt.Println(e)
+	}
+	return exitOK
+}
+
+func runConfig(o *cliOptions, args []string) int {
+	if len(args) != 1 {
+		return o.usageError("config requires one of: init, show, validate, schema")
+	}
+
+	switch args[0] {
+	case "init":
+		configFile := o.configFile
+		if configFile == "" {
+			configFile = defaultConfigFilePath
+		}
+		if _, err := os.Stat(configFile); err == nil && !o.force {
+			slog.Error(fmt.Sprintf("Config file already exists (use -force to overwrite): %s", configFile))
+			return exitError
+		}
+		if err := os.MkdirAll(filepath.Dir(configFile), 0750); err != nil {
+			slog.Error(fmt.Sprintf("Exiting due to error creating config file directory: %s", err))
+			return exitError
+		}
+		c := cmd.NewPlaybookConfig()
+		c.TempDirPath = defaultTempPath
+		err := c.GenerateTemplateFile(configFile)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Exiting due to error generating initial configuration file: %s", err))
+			return exitError
+		}
+		fmt.Printf("generated config file: %s\n", configFile)
+		return exitOK
+
+	case "show":
+		c, err := o.loadConfig(configIfExists)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Exiting due to %s", err))
+			return exitError
+		}
+		explain, err := c.ExplainConfig()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Exiting due to error explaining configuration: %s", err))
+			return exitError
+		}
+		fmt.Print(explain)
+		return exitOK
+
+	case "validate":
+		// config files are validated (known keys and types) while they are loaded
+		c, err := o.loadConfig(configIfExists)
+		if err == nil {
+			err = c.ValidateTuiParams()
+		}
+		if err == nil {
+			err = c.ValidateInputs()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration is not valid:\n%s\n", err)
+			return exitError
+		}
+		fmt.Printf("configuration is valid: %s\n", c.ConfigFilePath)
+		return exitOK
+
+	case "schema":
+		schema, err := cmd.ConfigJSONSchema()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Error generating JSON Schema: %s", err))
+			return exitError
+		}
+		fmt.Print(string(schema))
+		return exitOK
+	}
+
+	return o.usageError("unknown config command: %s", args[0])
+}
+
+func runImages(o *cliOptions, args []string) int {
+	if len(args) > 0 {
+		return o.usageError("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	c, err := o.loadConfig(configIfExists)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Exiting due to %s", err))
+		return exitError
+	}
+
+	images, err := cmd.ListContainerImages(c.Container.Runtime, c.Tui.ImageFilter)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error listing container images: %s", err))
+		return exitError
+	}
+	fmt.Println(strings.Join(images, "\n"))
+	return exitOK
+}
+
+func runVersion(o *cliOptions, args []string) int {
+	if len(args) > 0 {
+		return o.usageError("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	fmt.Printf("Version:\t%s\n", BuildVersion)
+	fmt.Printf("Build date:\t%s\n", BuildDate)
+	return exitOK
+}
@@ -2,9 +2,6 @@ package main
 
 import (
 	"a5e/cmd"
-	"a5e/tui"
-	"flag"
-	"fmt"
 	"log"
 	"log/slog"
 	"os"
@@ -19,161 +16,35 @@ var (
 	defaultConfigFilePath string = "./.ansible-tui/config.yml"
 )
 
+// CLI flags for playbook parameters.  These have the highest precedence and are applied
+// after config files (LoadConfig) and environment variables (ReadEnvs).
+var paramFlags = []struct {
+	name  string // CLI flag name
+	key   string // config file key passed to PlaybookConfig.SetParam
+	usage string
+}{
+	{"playbook", "playbook", "Playbook to run (PLAYBOOK)"},
+	{"inventory", "inventory", "Inventory file or directory (INVENTORY_FILE)"},
+	{"limit", "limit", "Limit hosts to a host, group, or pattern (LIMIT_HOST)"},
+	{"tags", "tags", "Only run tasks with these tags (ANSIBLE_TAGS)"},
+	{"skip-tags", "skip-tags", "Skip tasks with these tags (ANSIBLE_SKIP_TAGS)"},
+	{"extra-vars", "extra-vars-file", "Extra-vars file (EXTRA_VARS_FILE)"},
+	{"image", "image", "Container image to run ansible in (CONTAINER_IMAGE)"},
+	{"venv", "virtual-env-path", "Python virtual environment with ansible (VIRTUAL_ENV)"},
+	{"timeout", "playbook-timeout", "Playbook timeout in seconds (ANSIBLE_PLAYBOOK_TIMEOUT)"},
+	{"verbose-level", "verbose-level", "ansible-playbook verbosity 0-7 (VERBOSE_LEVEL)"},
+	{"extra-args", "extra-args", "Additional arguments for ansible-playbook (EXTRA_ARGS)"},
+}
+
 func main() {
 
 	// default log level to LevelWarn (prints WARN and ERROR)
 	slog.SetLogLoggerLevel(slog.LevelWarn)
 
 	// To allow for testing, os.Exit() should only be done from this main() function.
-	// All other functions should return errors (nil when no errors).
-
-	// read and parse CLI options
-	pbConfigFile := ""
-	displayVersion := flag.Bool("version", false, "Display version and exit")
-	generateTemplate := flag.Bool("g", false, "Generate ansible-tui.yml template and exit")
-	noTui := flag.Bool("nt", LookupEnvOrBool("NO_TUI", false), "No TUI.  Runs playbook from configuration file without TUI")
-	lintPlaybook := flag.Bool("lp", LookupEnvOrBool("LINT_PLAYBOOK", false), "Lint playbook.  Runs ansible-lint against playbook from configuration file without TUI")
-	lintAll := flag.Bool("la", LookupEnvOrBool("LINT_ALL", false), "Lint all.  Runs ansible-lint against all files without TUI")
-	flag.StringVar(&pbConfigFile, "c", LookupEnvOrString("PB_CONFIG_FILE", ""), "Playbook config file (PB_CONFIG_FILE)")
-	logLevel1 := flag.Bool("v", false, "Sets log level for ansible-tui to INFO (default WARN)")
-	logLevel2 := flag.Bool("vv", false, "Sets log level for ansible-tui to DEBUG (default WARN)")
-	flag.Parse()
-
-	// display version/build info if -version was passed to CLI
-	if *displayVersion {
-		fmt.Printf("Version:\t%s\n", BuildVersion)
-		fmt.Printf("Build date:\t%s\n", BuildDate)
-		os.Exit(0)
-	}
-
-	// set log level according to -v / -vv CLI options
-	if *logLevel1 {
-		slog.SetLogLoggerLevel(slog.LevelInfo)
-	}
-	if *logLevel2 {
-		slog.SetLogLoggerLevel(slog.LevelDebug)
-	}
-
-	// create new/empty PlaybookConfig from struct
-	c := cmd.NewPlaybookConfig()
-
-	// handle config file path and temp directory path
-	if pbConfigFile != "" {
-		c.ConfigFilePath = pbConfigFile
-	} else {
-		c.ConfigFilePath = defaultConfigFilePath
-	}
-	c.TempDirPath = defaultTempPath
-
-	var err error
-
-	// Set global configurations from pkg main's global variables
-
-	if *generateTemplate {
-		err = c.GenerateTemplateFile(defaultConfigFilePath)
-		if err != nil {
-			slog.Error(fmt.Sprintf("Exiting due to error generating initial configuration file: %s", err))
-			os.Exit(1)
-		}
-		os.Exit(0)
-	}
-
-	// if no config file was passed and TUI isn't disabled, generate a default config file
-	if pbConfigFile == "" && !*noTui {
-		// *noTui = false
-		pbConfigFile = defaultConfigFilePath
-		if _, err := os.Stat(pbConfigFile); os.IsNotExist(err) {
-			err = c.GenerateTemplateFile(defaultConfigFilePath)
-			if err != nil {
-				slog.Error(fmt.Sprintf("Exiting due to error generating initial configuration file: %s", err))
-				os.Exit(1)
-			}
-		}
-	}
-
-	// if running lint, don't use TUI
-	if *lintPlaybook || *lintAll {
-		*noTui = true
-		c.LintEnabled = true
-	}
-
-	// read config file into PlaybookConfig struct
-	err = c.ReadConf(pbConfigFile)
-	if err != nil {
-		slog.Error(fmt.Sprintf("Exiting due to config file error: %s", err))
-		os.Exit(1)
-	}
-
-	// read environment variables into PlaybookConfig struct
-	err = c.ReadEnvs()
-	if err != nil {
-		slog.Error(fmt.Sprintf("Exiting due error reading environment variables: %s", err))
-		os.Exit(1)
-	}
-
-	// Start TUI if config file was not specified or if -nt flag was not passed
-	if !*noTui {
-		slog.Debug("Creating TUI")
-		tui.BuildDate = BuildDate
-		tui.BuildVersion = BuildVersion
-		a, err := tui.NewTUI(c)
-		if err != nil {
-			slog.Error(fmt.Sprintf("Exiting due to errors starting TUI: %s", err))
-			os.Exit(1)
-		}
-
-		slog.Debug("Starting TUI")
-		err = a.Start()
-		if err != nil {
-			slog.Error(fmt.Sprintf("Exiting due to errors starting TUI: %s", err))
-			os.Exit(1)
-		}
-		// This should exit within the TUI to ensure proper exit (below should never run)
-		a.Stop()
-		os.Exit(0)
-	}
-
-	// process values in PlaybookConfig struct
-	err = c.ProcessEnvs()
-	if err != nil {
-		slog.Error(fmt.Sprintf("Exiting due to errors processing inputs: %s", err))
-		os.Exit(1)
-	}
-
-	// validate inputs in PlaybookConfig struct
-	err = c.ValidateInputs()
-	if err != nil {
-		slog.Error(fmt.Sprintf("Exiting due validation errors: %s", err))
-		os.Exit(1)
-	}
-
-	// Using PlaybookConfig struct, determine runtime environment (ansible in path, in Python venv, or container).
-	// If container execution, write struct to file, run ansible-tui inside container to read and execute ansible.
-	if *lintAll {
-		c.Metrics.ExitCode, err = c.RunAnsibleLint(".")
-		if err != nil {
-			slog.Error(fmt.Sprintf("Error running ansible-lint (all): %s", err))
-			os.Exit(1)
-		}
-	} else if *lintPlaybook {
-		c.Metrics.ExitCode, err = c.RunAnsibleLint(c.Playbook)
-		if err != nil {
-			slog.Error(fmt.Sprintf("Error running ansible-lint (playbook): %s", err))
-			os.Exit(1)
-		}
-	} else {
-		c.Metrics.ExitCode, err = c.RunAnsiblePlaybook()
-		if err != nil {
-			slog.Error(fmt.Sprintf("Error running playbook: %s", err))
-			os.Exit(1)
-		}
-	}
-
-	// Final exit code is based on the results of above RunAnsiblePlaybook method call
-	if err != nil {
-		os.Exit(c.Metrics.ExitCode)
-	}
-	os.Exit(c.Metrics.ExitCode)
+	// All other functions should return exit codes or errors (nil when no errors).
+	cmd.HandleSignals()
+	cmd.Exit(runCLI(os.Args[1:]))
 
 }
 
@@ -2,11 +2,16 @@ package main
 
 import (
 	"a5e/cmd"
+	"errors"
 	"log/slog"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // global
@@ -313,3 +318,805 @@ func TestAnsibleVaultVenv(t *testing.T) {
 // 	// b.Reset()
 
 // }
+
+func TestDetectInventory(t *testing.T) {
+
+	tests := map[string]cmd.InventoryType{
+		"./test/inventory-localhost.ini":   cmd.InventoryTypeIni,
+		"./test/inventory-no-file-ext":     cmd.InventoryTypeIni,
+		"./test/inventory-localhost.yml":   cmd.InventoryTypeYaml,
+		"./test/inventory-aws.aws_ec2.yml": cmd.InventoryTypePlugin,
+		"./test/inventory-script.sh":       cmd.InventoryTypeScript,
+		"./test/playbook-simple.yml":       cmd.InventoryTypeNone,
+		"./test/as-venv.yml":               cmd.InventoryTypeNone,
+		"./test/requirements.txt":          cmd.InventoryTypeNone,
+		"./test/vault-pw.txt":              cmd.InventoryTypeNone,
+	}
+
+	for file, expected := range tests {
+		info, err := cmd.DetectInventory(file)
+		if err != nil {
+			t.Errorf("Expected no errors detecting inventory for %s, got %s", file, err)
+		}
+		if info.Type != expected {
+			t.Errorf("Expected inventory type %q for %s, got %q", expected, file, info.Type)
+		}
+	}
+}
+
+func TestInventoryCache(t *testing.T) {
+
+	// fake ansible-inventory in a virtualenv that counts its runs
+	venv, dir := t.TempDir(), t.TempDir()
+	os.Mkdir(filepath.Join(venv, "bin"), 0700)
+	runs := filepath.Join(dir, "runs")
+	script := "#!/bin/sh\necho x >> " + runs + "\necho @all:\n"
+	err := os.WriteFile(filepath.Join(venv, "bin", "ansible-inventory"), []byte(script), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := filepath.Join(dir, "hosts.ini")
+	os.WriteFile(inv, []byte("[all]\nlocalhost\n"), 0600)
+
+	p := cmd.NewPlaybookConfig()
+	p.TempDirPath = t.TempDir()
+	p.VirtualEnvPath = venv
+
+	expectRuns := func(msg string, n int) {
+		t.Helper()
+		if _, err := p.GetAnsibleInventory(inv); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := os.ReadFile(runs)
+		if got := strings.Count(string(b), "x"); got != n {
+			t.Errorf("Expected %d ansible-inventory runs %s, got %d", n, msg, got)
+		}
+	}
+	expectRuns("for the first call", 1)
+	expectRuns("for a cache hit", 1)
+
+	os.WriteFile(inv, []byte("[all]\nlocalhost\nother\n"), 0600)
+	expectRuns("after the inventory changed", 2)
+
+	p.EnvironmentVariables.Set = map[string]string{"ANSIBLE_INVENTORY_ENABLED": "ini"}
+	expectRuns("after ANSIBLE_INVENTORY_ENABLED changed", 3)
+
+	// older entries of the same inventory are pruned
+	entries, _ := os.ReadDir(filepath.Join(p.TempDirPath, "inventory-cache"))
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 inventory cache entry after pruning, got %d", len(entries))
+	}
+}
+
+func TestDetectPlaybook(t *testing.T) {
+
+	tests := map[string]int{
+		"./test/playbook-simple.yml":     1,
+		"./test/playbook-import.yml":     2,
+		"./test/tasks-simple.yml":        0,
+		"./test/inventory-localhost.yml": 0,
+		"./test/as-venv.yml":             0,
+	}
+
+	for file, plays := range tests {
+		info, err := cmd.DetectPlaybook(file)
+		if err != nil {
+			t.Errorf("Expected no errors detecting playbook for %s, got %s", file, err)
+		}
+		if plays == 0 {
+			if info != nil {
+				t.Errorf("Expected %s not to be detected as a playbook", file)
+			}
+			continue
+		}
+		if info == nil || len(info.Plays) != plays {
+			t.Errorf("Expected %d plays in playbook %s, got %v", plays, file, info)
+		}
+	}
+
+	index := cmd.IndexPlaybooks([]string{"./test/playbook-simple.yml", "./test/tasks-simple.yml"})
+	if len(index) != 1 || index[0].Plays[0].Hosts != "localhost" {
+		t.Errorf("Expected playbook index with one playbook targeting localhost, got %v", index)
+	}
+}
+
+func TestScanner(t *testing.T) {
+
+	root := t.TempDir()
+	files := []string{
+		"site.yml",
+		"playbooks/web.yml",
+		"playbooks/old/legacy.yml",
+		".git/config.yml",
+		"node_modules/pkg/index.yml",
+		"env/pyvenv.cfg",
+		"env/lib/vars.yml",
+		"ignored/skip.yml",
+		"notes.txt",
+	}
+	for _, f := range files {
+		p := filepath.Join(root, f)
+		os.MkdirAll(filepath.Dir(p), 0750)
+		os.WriteFile(p, []byte("---\n"), 0640)
+	}
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("ignored/\n"), 0640)
+
+	s := cmd.NewScanner()
+	found, err := s.Scan(root, cmd.ScanOptions{
+		Exts:    []string{"yml"},
+		Recurse: true,
+		Exclude: []string{"playbooks/old/"},
+	})
+	if err != nil {
+		t.Errorf("Expected no errors scanning directory, got %s", err)
+	}
+	expected := []string{filepath.Join(root, "playbooks/web.yml"), filepath.Join(root, "site.yml")}
+	if strings.Join(found, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected scan results %v, got %v", expected, found)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+
+	p := cmd.NewPlaybookConfig()
+	p.Profile = "prod"
+	err := p.ReadConf("./test/as-profiles.yml")
+	if err != nil {
+		t.Errorf("Expected no errors reading config file with profile, got %s", err)
+	}
+	if p.RemoteUser != "deploy" || p.VerboseLevel != 0 || p.PlaybookTimeout != 600 {
+		t.Errorf("Expected prod profile values to override base values, got %s, %d, %d", p.RemoteUser, p.VerboseLevel, p.PlaybookTimeout)
+	}
+	if p.Playbook != "./test/playbook-simple.yml" {
+		t.Errorf("Expected base playbook when not set in profile, got %s", p.Playbook)
+	}
+	if names := p.ProfileNames(); strings.Join(names, ",") != "dev,prod" {
+		t.Errorf("Expected profiles dev and prod, got %v", names)
+	}
+
+	p = cmd.NewPlaybookConfig()
+	p.Profile = "missing"
+	err = p.ReadConf("./test/as-profiles.yml")
+	if err == nil {
+		t.Errorf("Expected error reading config file with missing profile, got %s", err)
+	}
+}
+
+func TestExplainConfig(t *testing.T) {
+
+	os.Setenv("LIMIT_HOST", "localhost")
+	defer os.Unsetenv("LIMIT_HOST")
+	t.Setenv("PLAYBOOK", "") // set by earlier tests
+
+	p := cmd.NewPlaybookConfig()
+	p.TempDirPath = defaultTempPath
+	p.ConfigFilePath = "./test/as-profiles.yml"
+	p.Profile = "prod"
+	p.EnvironmentVariables.Set = map[string]string{"VAULT_TOKEN": "s3cr3t"}
+
+	err := p.LoadConfig(p.ConfigFilePath)
+	if err != nil {
+		t.Errorf("Expected no errors loading config, got %s", err)
+	}
+	err = p.ReadEnvs()
+	if err != nil {
+		t.Errorf("Expected no errors reading environment variables, got %s", err)
+	}
+
+	sources := map[string]string{
+		"playbook":         cmd.SourceProject,
+		"remote-user":      cmd.SourceProfile,
+		"limit":            cmd.SourceEnv,
+		"windows-group":    cmd.SourceDefault,
+		"playbook-timeout": cmd.SourceProfile,
+	}
+	for key, source := range sources {
+		if !strings.HasPrefix(p.Source(key), source) {
+			t.Errorf("Expected source %s for %s, got %s", source, key, p.Source(key))
+		}
+	}
+
+	explain, err := p.ExplainConfig()
+	if err != nil {
+		t.Errorf("Expected no errors explaining config, got %s", err)
+	}
+	if strings.Contains(explain, "s3cr3t") {
+		t.Errorf("Expected secret values to be redacted in explain output")
+	}
+}
+
+func TestSetParam(t *testing.T) {
+
+	p := cmd.NewPlaybookConfig()
+
+	err := p.SetParam("limit", "web1", cmd.SourceFlag+" (-limit)")
+	if err != nil || p.LimitHost != "web1" {
+		t.Errorf("Expected limit to be set by SetParam, got %s, %v", p.LimitHost, err)
+	}
+	if p.Source("limit") != cmd.SourceFlag+" (-limit)" {
+		t.Errorf("Expected flag source for limit, got %s", p.Source("limit"))
+	}
+
+	err = p.SetParam("playbook-timeout", "sixty", cmd.SourceFlag)
+	if err == nil {
+		t.Errorf("Expected error setting non-integer playbook-timeout, got %s", err)
+	}
+
+	err = p.SetParam("inventroy", "./hosts", cmd.SourceFlag)
+	if err == nil {
+		t.Errorf("Expected error setting unknown parameter, got %s", err)
+	}
+}
+
+func TestCLIExitCodes(t *testing.T) {
+
+	tests := []struct {
+		args []string
+		rc   int
+	}{
+		{[]string{"version"}, exitOK},
+		{[]string{"help", "lint"}, exitOK},
+		{[]string{"bogus"}, exitUsage},
+		{[]string{"lint", "bogus"}, exitUsage},
+		{[]string{"inventory"}, exitUsage},
+		{[]string{"history", "bogus"}, exitUsage},
+		{[]string{"config", "show", "-nt"}, exitUsage},
+		{[]string{"config", "show", "-c", "./test/as-venv.yml"}, exitOK},
+		{[]string{"config", "show", "-c", "./test/does-not-exist.yml"}, exitError},
+	}
+
+	for _, tt := range tests {
+		rc := runCLI(tt.args)
+		if rc != tt.rc {
+			t.Errorf("Expected rc=%d for %s, got %d", tt.rc, strings.Join(tt.args, " "), rc)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+
+	p := cmd.NewPlaybookConfig()
+	p.TempDirPath = t.TempDir()
+	p.Playbook = "site.yml"
+	p.InventoryFile = "hosts.ini"
+
+	for rc := 0; rc < 3; rc++ {
+		err := p.RecordRun(time.Now(), rc, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := p.RecordRun(time.Now(), 1, errors.New("timed out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := p.ReadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[2].ExitCode != 2 || entries[3].Error != "timed out" {
+		t.Errorf("Expected 4 runs in order with the error of the last run, got %+v", entries)
+	}
+	if !strings.Contains(entries[0].String(), "rc=0") || !strings.Contains(entries[0].String(), "site.yml -i hosts.ini") {
+		t.Errorf("Unexpected history line: %s", entries[0])
+	}
+
+	err = p.ClearHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err = p.ReadHistory()
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected empty history after clear, got %d entries (%s)", len(entries), err)
+	}
+}
+
+func TestLintPolicyMerge(t *testing.T) {
+
+	// projectLintConfigFile looks for .ansible-lint in the current directory
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	err := os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(".ansible-lint", []byte("profile: basic\nskip_list:\n  - yaml\n  - name\nexclude_paths:\n  - tests/\n"), 0600)
+	policyFile := filepath.Join(t.TempDir(), "policy.yml")
+	os.WriteFile(policyFile, []byte("profile: production\nenable_list:\n  - yaml\nexclude_paths:\n  - .cache/\n"), 0600)
+
+	p := cmd.NewPlaybookConfig()
+	p.TempDirPath = t.TempDir()
+	defer cmd.CleanupRunDirs()
+
+	f, err := p.ResolveLintConfig(cmd.GlobalConfigForce{AnsibleLintFilePath: policyFile, AnsibleLintPolicyVersion: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(f) != p.RunDir {
+		t.Errorf("Expected merged config in the run directory %s, got %s", p.RunDir, f)
+	}
+	if info, err := os.Stat(f); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected merged config with mode 0600, got %v (%s)", info.Mode().Perm(), err)
+	}
+
+	b, _ := os.ReadFile(f)
+	if !strings.Contains(string(b), "merged with policy "+policyFile+" (version v1)") {
+		t.Errorf("Expected header with the policy and version, got %s", b)
+	}
+	merged := struct {
+		Profile      string   `yaml:"profile"`
+		SkipList     []string `yaml:"skip_list"`
+		ExcludePaths []string `yaml:"exclude_paths"`
+	}{}
+	err = yaml.Unmarshal(b, &merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Profile != "production" || strings.Join(merged.SkipList, ",") != "name" || strings.Join(merged.ExcludePaths, ",") != "tests/,.cache/" {
+		t.Errorf("Unexpected merged config: %+v", merged)
+	}
+
+	_, err = p.ResolveLintConfig(cmd.GlobalConfigForce{AnsibleLintFilePath: policyFile, AnsibleLintFileChecksum: "sha256:00"})
+	if err == nil {
+		t.Errorf("Expected error for a policy that doesn't match ansible-lint-file-checksum")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+
+	f := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(f, []byte("---\ninventroy: ./hosts\nverbose-level: two\ntui:\n  playbook-dir: ./playbooks\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cmd.ValidateConfigFile(f)
+	if err == nil {
+		t.Errorf("Expected errors for unknown key and wrong type, got %s", err)
+	} else {
+		if !strings.Contains(err.Error(), f+":2:1: unknown key inventroy (did you mean inventory?)") {
+			t.Errorf("Expected unknown key error with position, got %s", err)
+		}
+		if !strings.Contains(err.Error(), f+":3:16: verbose-level must be an integer") {
+			t.Errorf("Expected type error with position, got %s", err)
+		}
+	}
+
+	err = cmd.ValidateConfigFile("./test/as-profiles.yml")
+	if err != nil {
+		t.Errorf("Expected valid config file, got %s", err)
+	}
+
+	// internal keys are only accepted in the config generated for a container run
+	err = os.WriteFile(f, []byte("---\nrundir: /tmp\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.ValidateConfigFile(f)
+	if err == nil || !strings.Contains(err.Error(), "unknown key rundir") {
+		t.Errorf("Expected unknown key error for rundir, got %s", err)
+	}
+	t.Setenv("ANSIBLE_TUI_CONTAINER_CONFIG", f)
+	err = cmd.ValidateConfigFile(f)
+	if err != nil {
+		t.Errorf("Expected rundir to be accepted in container config, got %s", err)
+	}
+
+	// published JSON Schema must match the config struct
+	schema, err := cmd.ConfigJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, _ := os.ReadFile("./ansible-tui.schema.json")
+	if string(schema) != string(published) {
+		t.Errorf("Expected ansible-tui.schema.json to match config schema, run: ansible-tui config schema > ansible-tui.schema.json")
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+
+	t.Setenv("TEST_REMOTE_USER", "alice")
+	t.Setenv("TEST_VERBOSE_LEVEL", "2")
+
+	f := filepath.Join(t.TempDir(), "config.yml")
+	contents := "---\nremote-user: ${TEST_REMOTE_USER}\nssh-private-key-file: ${TEST_WORKSPACE:-/ci}/id_rsa\nverbose-level: ${TEST_VERBOSE_LEVEL}\nextra-args: --extra $$HOME\n"
+	err := os.WriteFile(f, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := cmd.NewPlaybookConfig()
+	err = p.ReadConf(f)
+	if err != nil {
+		t.Fatalf("Expected config with variables to be read, got %s", err)
+	}
+	if p.RemoteUser != "alice" {
+		t.Errorf("Expected remote-user alice, got %s", p.RemoteUser)
+	}
+	if p.SshPrivateKeyFile != "/ci/id_rsa" {
+		t.Errorf("Expected default for unset variable, got %s", p.SshPrivateKeyFile)
+	}
+	if p.VerboseLevel != 2 {
+		t.Errorf("Expected verbose-level 2, got %d", p.VerboseLevel)
+	}
+	if p.ExtraArgs != "--extra $HOME" {
+		t.Errorf("Expected escaped $, got %s", p.ExtraArgs)
+	}
+
+	// variable references are written back instead of expanded values
+	node := yaml.Node{}
+	_ = node.Encode(p)
+	p.RevertInterpolation(&node)
+	b, _ := yaml.Marshal(&node)
+	for _, s := range []string{"${TEST_REMOTE_USER}", "${TEST_WORKSPACE:-/ci}/id_rsa", "--extra $$HOME"} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("Expected %s in written config, got %s", s, b)
+		}
+	}
+}
+
+func TestTuiEnvs(t *testing.T) {
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TUI_PLAYBOOK_DIR", "./test")
+	t.Setenv("TUI_VIRTUAL_ENVS_DIR", "~")
+	t.Setenv("TUI_IMAGE_FILTER", "")
+
+	p := cmd.NewPlaybookConfig()
+	p.TempDirPath = t.TempDir()
+	p.Tui.ImageFilter = "ansible"
+	err := p.ReadEnvs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Tui.PlaybookDir != "./test" || p.Tui.ImageFilter != "" {
+		t.Errorf("Expected TUI parameters from environment variables, got %s, %s", p.Tui.PlaybookDir, p.Tui.ImageFilter)
+	}
+	if p.Tui.VirtualEnvsPath() != home {
+		t.Errorf("Expected ~ to be expanded in virtual-envs-dir, got %s", p.Tui.VirtualEnvsPath())
+	}
+	err = p.ValidateTuiParams()
+	if err != nil {
+		t.Errorf("Expected valid TUI parameters, got %s", err)
+	}
+
+	t.Setenv("TUI_INVENTORY_DIR", "./does-not-exist")
+	err = p.ReadEnvs()
+	if err == nil {
+		err = p.ValidateTuiParams()
+	}
+	if err == nil {
+		t.Errorf("Expected error for missing inventory-dir, got %s", err)
+	}
+}
+
+func TestAnsibleConfigFile(t *testing.T) {
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ANSIBLE_CONFIG", "")
+
+	p := cmd.NewPlaybookConfig()
+	p.EnvironmentVariables.Set = map[string]string{"ANSIBLE_CONFIG": "./test/ansible.cfg"}
+	path, source := p.AnsibleConfigFile()
+	if path != "./test/ansible.cfg" || source != "ANSIBLE_CONFIG" {
+		t.Errorf("Expected ansible.cfg from ANSIBLE_CONFIG, got %s (%s)", path, source)
+	}
+
+	p.EnvironmentVariables.Set = nil
+	path, source = p.AnsibleConfigFile()
+	if source == "ANSIBLE_CONFIG" || source == "home directory" {
+		t.Errorf("Expected ansible.cfg without overrides, got %s (%s)", path, source)
+	}
+
+	// the host's /etc/ansible/ansible.cfg does not apply to container runs
+	p.Image = "ansible-tui:latest"
+	path, source = p.AnsibleConfigFile()
+	if source != "image" {
+		t.Errorf("Expected ansible.cfg from the image, got %s (%s)", path, source)
+	}
+}
+
+func TestSecretEnvs(t *testing.T) {
+
+	t.Setenv("DB_PASSWORD", "s3cr3t")
+
+	conf := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(conf, []byte("environment-variables:\n  set:\n    DB_PASSWORD: ${DB_PASSWORD}\n    VAULT_ID: abc123\n    LOG_LEVEL: info\n  secret:\n    - VAULT_ID\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := cmd.NewPlaybookConfig()
+	err = p.ReadConf(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.IsSecretEnv("DB_PASSWORD") || !p.IsSecretEnv("VAULT_ID") || p.IsSecretEnv("LOG_LEVEL") {
+		t.Errorf("Expected DB_PASSWORD and VAULT_ID to be secret")
+	}
+	for _, name := range []string{"SSH_AUTH_SOCK", "ANSIBLE_VAULT_PASSWORD_FILE", "PASSTHROUGH"} {
+		if p.IsSecretEnv(name) {
+			t.Errorf("Expected %s not to be secret", name)
+		}
+	}
+
+	envs := p.WritableEnvs()
+	if _, ok := envs["VAULT_ID"]; ok {
+		t.Errorf("Expected secret value of VAULT_ID not to be writable, got %s", envs["VAULT_ID"])
+	}
+	if envs["DB_PASSWORD"] != "s3cr3t" || envs["LOG_LEVEL"] != "info" {
+		t.Errorf("Expected DB_PASSWORD (from ${DB_PASSWORD}) and LOG_LEVEL to be writable, got %v", envs)
+	}
+}
+
+func TestEnvPassList(t *testing.T) {
+
+	t.Setenv("ANSIBLE_STDOUT_CALLBACK", "yaml")
+	t.Setenv("ANSIBLE_VAULT_PASSWORD_FILE", "/tmp/vault")
+	t.Setenv("UNRELATED", "1")
+
+	p := cmd.NewPlaybookConfig()
+	p.EnvironmentVariables.Pass = []string{"ANSIBLE_*"}
+	p.EnvironmentVariables.Deny = []string{"ANSIBLE_VAULT_*"}
+	p.EnvironmentVariables.Set = map[string]string{"ANSIBLE_STDOUT_CALLBACK": "json"}
+
+	entries, err := p.EnvPassList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := make(map[string]string)
+	for _, e := range entries {
+		status[e.Name] = e.Status
+	}
+	expected := map[string]string{
+		"ANSIBLE_STDOUT_CALLBACK":     cmd.EnvSet,
+		"ANSIBLE_VAULT_PASSWORD_FILE": cmd.EnvDenied,
+		"PATH":                        cmd.EnvPassed,
+		"UNRELATED":                   "",
+	}
+	for k, v := range expected {
+		if status[k] != v {
+			t.Errorf("Expected %s for %s, got %s", v, k, status[k])
+		}
+	}
+
+	// the environment of ansible-tui is not changed
+	path := os.Getenv("PATH")
+	err = p.ProcessEnvs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("UNRELATED") != "1" || os.Getenv("PATH") != path {
+		t.Errorf("Expected environment of ansible-tui to be unchanged, got UNRELATED=%s", os.Getenv("UNRELATED"))
+	}
+	if p.EnvironmentVariables.Set["ANSIBLE_STDOUT_CALLBACK"] != "json" {
+		t.Errorf("Expected set value for ANSIBLE_STDOUT_CALLBACK, got %s", p.EnvironmentVariables.Set["ANSIBLE_STDOUT_CALLBACK"])
+	}
+
+	// the config is not changed either (passed values are resolved for each command)
+	if len(p.EnvironmentVariables.Set) != 1 || len(p.EnvironmentVariables.Pass) != 1 {
+		t.Errorf("Expected environment-variables to be unchanged, got set %v and pass %v", p.EnvironmentVariables.Set, p.EnvironmentVariables.Pass)
+	}
+}
+
+func TestPathPolicy(t *testing.T) {
+
+	p := cmd.NewPlaybookConfig()
+	p.LintEnabled = true
+
+	p.Playbook = "test/playbook-simple.yml"
+	err := p.ValidateInputs()
+	if err != nil {
+		t.Errorf("Expected relative playbook path to be valid, got %s", err)
+	}
+
+	abs, _ := filepath.Abs("test/playbook-simple.yml")
+	p.Playbook = abs
+	err = p.ValidateInputs()
+	if err == nil || !strings.Contains(err.Error(), "path-policy.allow-absolute") {
+		t.Errorf("Expected error for absolute playbook path, got %v", err)
+	}
+
+	p.PathPolicy.AllowAbsolute = true
+	err = p.ValidateInputs()
+	if err != nil {
+		t.Errorf("Expected absolute playbook path under current directory to be valid, got %s", err)
+	}
+
+	// symlink from an allowed root to a file outside of it
+	root, outside := t.TempDir(), t.TempDir()
+	err = os.WriteFile(filepath.Join(outside, "site.yml"), []byte("---\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(filepath.Join(outside, "site.yml"), filepath.Join(root, "site.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.PathPolicy.AllowedRoots = []string{root}
+	p.Playbook = filepath.Join(root, "site.yml")
+	err = p.ValidateInputs()
+	if err == nil || !strings.Contains(err.Error(), "outside the allowed roots") {
+		t.Errorf("Expected error for symlink outside allowed roots, got %v", err)
+	}
+
+	// the policy is checked before the path exists, so the error doesn't tell if a file exists
+	p.Playbook = filepath.Join(outside, "missing.yml")
+	err = p.ValidateInputs()
+	if err == nil || !strings.Contains(err.Error(), "is not under an allowed root") {
+		t.Errorf("Expected error for missing path outside allowed roots, got %v", err)
+	}
+
+	// container runs only see the current directory and container.mounts
+	mnt := t.TempDir()
+	err = os.WriteFile(filepath.Join(mnt, "site.yml"), []byte("---\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Image = "example/image"
+	p.PathPolicy.AllowedRoots = []string{".", mnt}
+	p.Playbook = filepath.Join(mnt, "site.yml")
+	err = p.ValidateInputs()
+	if err == nil || !strings.Contains(err.Error(), "outside the current directory and container.mounts") {
+		t.Errorf("Expected error for container path that is not mounted, got %v", err)
+	}
+	p.Container.Mounts = []cmd.ContainerMount{{Source: mnt, Target: "/srv/playbooks"}}
+	err = p.ValidateInputs()
+	if err != nil || p.Playbook != "/srv/playbooks/site.yml" {
+		t.Errorf("Expected playbook under the mount target, got %s (%v)", p.Playbook, err)
+	}
+	p.Playbook = abs
+	err = p.ValidateInputs()
+	if err != nil || p.Playbook != "./test/playbook-simple.yml" {
+		t.Errorf("Expected container path relative to the current directory, got %s (%v)", p.Playbook, err)
+	}
+}
+
+func TestContentsEnvs(t *testing.T) {
+
+	t.Setenv("INVENTORY_FILE", "") // set by earlier tests
+	t.Setenv("INVENTORY_CONTENTS", "[all]\nlocalhost\n")
+	t.Setenv("EXTRA_VARS_CONTENTS", "---\nkey: value\n")
+	t.Setenv("SSH_PRIVATE_KEY_CONTENTS", "not a key")
+
+	p := cmd.NewPlaybookConfig()
+	p.TempDirPath = t.TempDir()
+	err := p.ReadEnvs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runDir := filepath.Dir(p.InventoryFile)
+	if filepath.Dir(runDir) != p.TempDirPath || filepath.Dir(p.ExtraVarsFile) != runDir || filepath.Dir(p.SshPrivateKeyFile) != runDir {
+		t.Errorf("Expected files from *_CONTENTS in a run directory under temp-dir, got %s, %s, %s", p.InventoryFile, p.ExtraVarsFile, p.SshPrivateKeyFile)
+	}
+	stat, err := os.Stat(runDir)
+	if err != nil || stat.Mode().Perm() != 0700 {
+		t.Errorf("Expected run directory with mode 0700, got %v", err)
+	}
+
+	cmd.CleanupRunDirs()
+	if ok, _ := os.Stat(runDir); ok != nil {
+		t.Errorf("Expected run directory to be removed, got %s", runDir)
+	}
+
+	t.Setenv("SSH_PRIVATE_KEY_FILE", "~/.ssh/id_rsa")
+	err = cmd.NewPlaybookConfig().ReadEnvs()
+	if err == nil {
+		t.Errorf("Expected error for SSH_PRIVATE_KEY_FILE and SSH_PRIVATE_KEY_CONTENTS, got %s", err)
+	}
+}
+
+func TestPullPolicy(t *testing.T) {
+
+	p := cmd.NewPlaybookConfig()
+	p.LintEnabled = true
+	p.Playbook = "test/playbook-simple.yml"
+
+	err := p.SetParam("pull-policy", "sometimes", cmd.SourceFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.ValidateInputs()
+	if err == nil || !strings.Contains(err.Error(), "pull-policy") {
+		t.Errorf("Expected error for invalid pull-policy, got %v", err)
+	}
+
+	p.PullPolicy = cmd.PullNever
+	if p.NeedsImagePull() {
+		t.Errorf("Expected no image pull without an image, got %t", p.NeedsImagePull())
+	}
+	p.Image = "quay.io/ansible/creator-ee"
+	if !p.NeedsImagePull() {
+		t.Errorf("Expected image pull before the first container run, got %t", p.NeedsImagePull())
+	}
+}
+
+func TestContainerConfig(t *testing.T) {
+
+	t.Setenv("CONTAINER_RUNTIME", "/usr/local/bin/podman")
+
+	p := cmd.NewPlaybookConfig()
+	p.TempDirPath = t.TempDir()
+	err := p.ReadEnvs()
+	if err != nil || p.Container.Runtime != "/usr/local/bin/podman" {
+		t.Errorf("Expected container runtime from CONTAINER_RUNTIME, got %s, %v", p.Container.Runtime, err)
+	}
+
+	p.LintEnabled = true
+	p.Playbook = "test/playbook-simple.yml"
+	p.Image = "quay.io/ansible/creator-ee"
+	p.Container.Network = "host; rm -rf /"
+	err = p.ValidateInputs()
+	if err == nil || !strings.Contains(err.Error(), "container.network") {
+		t.Errorf("Expected error for invalid container.network, got %v", err)
+	}
+	p.Container.Network = "host"
+
+	abs, _ := filepath.Abs("test")
+	mounts := []struct {
+		mount cmd.ContainerMount
+		valid bool
+	}{
+		{cmd.ContainerMount{Source: abs, Target: "/etc/pki"}, true},
+		{cmd.ContainerMount{Source: abs + "/missing", Target: "/etc/pki"}, false},
+		{cmd.ContainerMount{Source: abs, Target: "/app/test"}, false},
+		{cmd.ContainerMount{Source: abs, Target: "etc/pki"}, false},
+		{cmd.ContainerMount{Source: abs, Target: "/etc/pki", Mode: "wr"}, false},
+	}
+	for _, m := range mounts {
+		p.Container.Mounts = []cmd.ContainerMount{m.mount}
+		err = p.ValidateInputs()
+		if (err == nil) != m.valid {
+			t.Errorf("Expected mount %v valid=%t, got %v", m.mount, m.valid, err)
+		}
+	}
+	p.Container.Mounts = nil
+
+	p.Container.Mode = "ee"
+	err = p.ValidateInputs()
+	if err == nil || !strings.Contains(err.Error(), "container.mode") {
+		t.Errorf("Expected error for invalid container.mode, got %v", err)
+	}
+	p.Container.Mode = cmd.ContainerModeDirect
+
+	p.Container.Resources.Memory = "2x"
+	err = p.ValidateInputs()
+	if err == nil || !strings.Contains(err.Error(), "container.resources.memory") {
+		t.Errorf("Expected error for invalid container.resources.memory, got %v", err)
+	}
+}
+
+func TestSshAgent(t *testing.T) {
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	p := cmd.NewPlaybookConfig()
+	p.Playbook = "test/playbook-simple.yml"
+	p.InventoryFile = "test/inventory-localhost.ini"
+	err = p.ValidateInputs()
+	if err != nil || !p.UsesSshAgent() {
+		t.Errorf("Expected SSH agent to be used without ssh-private-key-file, got %t, %v", p.UsesSshAgent(), err)
+	}
+
+	p.SshAgent = cmd.SshAgentNever
+	err = p.ValidateInputs()
+	if err != nil || p.UsesSshAgent() {
+		t.Errorf("Expected SSH agent not to be used with ssh-agent never, got %t, %v", p.UsesSshAgent(), err)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	p.SshAgent = cmd.SshAgentAlways
+	err = p.ValidateInputs()
+	if err == nil {
+		t.Errorf("Expected error for ssh-agent always without an agent, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+#!/bin/sh
+# Minimal dynamic inventory script (supports --list and --host)
+if [ "$1" = "--list" ]; then
+  echo '{"local": {"hosts": ["localhost"], "vars": {"ansible_connection": "local"}}, "_meta": {"hostvars": {}}}'
+else
+  echo '{}'
+fi
@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -20,10 +21,19 @@ func (tui *TUI) save() {
 
 	// write PlaybookConfig struct to file
 
-	// Make a copy of config to manipulate before writing to file
-	writeConfig := tui.toWriteConfig()
+	// only values from the project config file, the active profile and edits in the TUI are written
+	node, err := tui.toWriteNode()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Could not save config %s: %s", tui.pbConfig.ConfigFilePath, err))
+		tui.pages.SwitchToPage("main text")
+		tui.textMain1.SetText(fmt.Sprintf("Error saving config %s: %s", tui.pbConfig.ConfigFilePath, err))
+		return
+	}
+
+	// write ${VAR} references from the config file instead of their expanded values
+	tui.pbConfig.RevertInterpolation(node)
 
-	b, err := yaml.Marshal(&writeConfig)
+	b, err := yaml.Marshal(node)
 	if err != nil {
 		slog.Error("Could not marshal PlaybookConfig to bytes")
 	}
@@ -37,6 +47,200 @@ func (tui *TUI) save() {
 	tui.textMain1.SetText(fmt.Sprintf("Wrote config to %s:\n\n%s", tui.pbConfig.ConfigFilePath, b))
 }
 
+// Returns the config file contents to write.  Values from other layers (global defaults, user config,
+// environment variables and CLI flags) are not written unless they were changed in the TUI.  Without a
+// profile, project values overridden by another layer keep their value from the file.  With an active
+// profile, base values are left as they are in the file and only the profile is updated.
+func (tui *TUI) toWriteNode() (*yaml.Node, error) {
+
+	base := cmd.NewPlaybookConfig()
+	if _, err := os.Stat(tui.pbConfig.ConfigFilePath); err == nil {
+		err := base.ReadConf(tui.pbConfig.ConfigFilePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fromFile := func(key string) bool {
+		return strings.HasPrefix(base.Source(key), cmd.SourceProject)
+	}
+
+	fileNode, err := writeConfigNode(newWriteConfig(base))
+	if err != nil {
+		return nil, err
+	}
+	fileLeaves := configLeaves(fileNode)
+
+	current, err := writeConfigNode(tui.toWriteConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	if tui.pbConfig.Profile == "" {
+		filterConfigNode(current, "", func(key string, value *yaml.Node) *yaml.Node {
+			switch {
+			case tui.edited(key, value), strings.HasPrefix(tui.pbConfig.Source(key), cmd.SourceProject):
+				return value
+			case fromFile(key):
+				return fileLeaves[key]
+			}
+			return nil
+		})
+		return current, nil
+	}
+
+	oldProfile := make(map[string]*yaml.Node)
+	if node, ok := base.Profiles[tui.pbConfig.Profile]; ok {
+		oldProfile = configLeaves(&node)
+	}
+
+	profile := &yaml.Node{Kind: yaml.MappingNode}
+	for key, value := range configLeaves(current) {
+		switch {
+		case tui.edited(key, value), strings.HasPrefix(tui.pbConfig.Source(key), cmd.SourceProfile):
+			setConfigLeaf(profile, key, value)
+		case oldProfile[key] != nil:
+			setConfigLeaf(profile, key, oldProfile[key])
+		}
+	}
+	sortConfigNode(profile)
+
+	filterConfigNode(fileNode, "", func(key string, value *yaml.Node) *yaml.Node {
+		if fromFile(key) {
+			return value
+		}
+		return nil
+	})
+	profiles := make(map[string]yaml.Node)
+	for k, v := range base.Profiles {
+		profiles[k] = v
+	}
+	profiles[tui.pbConfig.Profile] = *profile
+	profilesNode := &yaml.Node{}
+	if err := profilesNode.Encode(profiles); err != nil {
+		return nil, err
+	}
+	setConfigLeaf(fileNode, "profiles", profilesNode)
+
+	return fileNode, nil
+}
+
+// True if the value of key was changed in the TUI since the config was loaded
+func (tui *TUI) edited(key string, value *yaml.Node) bool {
+	return leafString(value) != tui.loaded[key]
+}
+
+// Remember the loaded values so edits in the TUI can be told apart from values of other layers
+func (tui *TUI) snapshotConfig() {
+	tui.loaded = make(map[string]string)
+	node, err := writeConfigNode(tui.toWriteConfig())
+	if err != nil {
+		slog.Error(fmt.Sprintf("Could not encode PlaybookConfig: %s", err))
+		return
+	}
+	for key, value := range configLeaves(node) {
+		tui.loaded[key] = leafString(value)
+	}
+}
+
+func writeConfigNode(wc writeConfig) (*yaml.Node, error) {
+	node := yaml.Node{}
+	err := node.Encode(&wc)
+	return &node, err
+}
+
+func leafString(value *yaml.Node) string {
+	if value.Kind == yaml.ScalarNode {
+		return value.Value
+	}
+	b, _ := yaml.Marshal(value)
+	return string(b)
+}
+
+// Leaves of a config node by dotted key (ex. "tui.playbook-dir").  Lists are leaves and profiles are skipped.
+func configLeaves(node *yaml.Node) map[string]*yaml.Node {
+	leaves := make(map[string]*yaml.Node)
+	filterConfigNode(node, "", func(key string, value *yaml.Node) *yaml.Node {
+		leaves[key] = value
+		return value
+	})
+	return leaves
+}
+
+// Replace each leaf of a config node with the result of fn and remove leaves where fn returns nil.
+// Mappings left empty are removed and the profiles section is left as it is.
+func filterConfigNode(node *yaml.Node, prefix string, fn func(key string, value *yaml.Node) *yaml.Node) {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		filterConfigNode(node.Content[0], prefix, fn)
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	var content []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		value := node.Content[i+1]
+		if prefix == "" && key == "profiles" {
+			content = append(content, node.Content[i], value)
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if value.Kind == yaml.MappingNode && len(value.Content) > 0 {
+			filterConfigNode(value, key, fn)
+			if len(value.Content) > 0 {
+				content = append(content, node.Content[i], value)
+			}
+			continue
+		}
+		if v := fn(key, value); v != nil {
+			content = append(content, node.Content[i], v)
+		}
+	}
+	node.Content = content
+}
+
+// Set a leaf of a mapping node by dotted key, creating the parent mappings
+func setConfigLeaf(node *yaml.Node, key string, value *yaml.Node) {
+	name, rest, nested := strings.Cut(key, ".")
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != name {
+			continue
+		}
+		if nested {
+			setConfigLeaf(node.Content[i+1], rest, value)
+		} else {
+			node.Content[i+1] = value
+		}
+		return
+	}
+	if nested {
+		child := &yaml.Node{Kind: yaml.MappingNode}
+		setConfigLeaf(child, rest, value)
+		value = child
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+}
+
+// Sort the keys of a mapping node (and nested mappings) so profiles are written in a stable order
+func sortConfigNode(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	type pair struct{ key, value *yaml.Node }
+	var pairs []pair
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		sortConfigNode(node.Content[i+1])
+		pairs = append(pairs, pair{node.Content[i], node.Content[i+1]})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].key.Value < pairs[j].key.Value })
+	node.Content = node.Content[:0]
+	for _, p := range pairs {
+		node.Content = append(node.Content, p.key, p.value)
+	}
+}
+
 func (tui *TUI) saveAdvancedForm() {
 
 	// Get verbose-level from form and convert to integer
@@ -97,6 +301,14 @@ func (tui *TUI) renderHeader() {
 		`, "<i>", "inspect", "<esc>", "back", "ansible-tui",
 			"<v>", "verify", "enter", "select", BuildVersion,
 			"<a>", "show all", "", "", BuildDate)
+	case "Limits":
+		headerText = fmt.Sprintf(
+			`%-7s %-10s %-7s %-10s %-10s
+%-7s %-10s %-7s %-10s %-10s
+%-7s %-10s %-7s %-10s %-10s
+	`, "<r>", "refresh", "<esc>", "back", "ansible-tui",
+			"", "", "enter", "select", BuildVersion,
+			"", "", "", "", BuildDate)
 	}
 
 	// tui.textTop.SetText(fmt.Sprintf("version: %s\ndate: %s", BuildVersion, BuildDate))
@@ -104,7 +316,15 @@ func (tui *TUI) renderHeader() {
 }
 
 func (tui *TUI) renderFooter(msg string) {
-	tui.textFooter.SetText(fmt.Sprintf("config: %s", msg))
+	footer := fmt.Sprintf("config: %s", msg)
+	if tui.pbConfig.Profile != "" || len(tui.pbConfig.Profiles) > 0 {
+		profile := tui.pbConfig.Profile
+		if profile == "" {
+			profile = "(none)"
+		}
+		footer += fmt.Sprintf("    profile: %s", profile)
+	}
+	tui.textFooter.SetText(footer)
 }
 
 func (tui *TUI) lintMenu() {
@@ -158,16 +378,156 @@ func (tui *TUI) lintMenu() {
 	tui.app.Sync() // without this, listing images corrupts the screen
 }
 
+func (tui *TUI) listProfiles() {
+	tui.editParam = "Profiles"
+
+	tui.renderHeader()
+	tui.pages.SwitchToPage("main table")
+	tui.tableMain.Clear()
+	tui.tableMain.SetTitle("Profiles")
+	tui.tableMain.SetSelectable(true, false)
+
+	// first row clears the profile (base configuration only)
+	profiles := append([]string{"(none)"}, tui.pbConfig.ProfileNames()...)
+	for idx, profile := range profiles {
+		if (idx == 0 && tui.pbConfig.Profile == "") || (idx > 0 && profile == tui.pbConfig.Profile) {
+			profile += " (active)"
+		}
+		tui.tableMain.SetCell(
+			idx, 1,
+			&tview.TableCell{
+				Text:          profile,
+				Color:         tcell.ColorYellow,
+				NotSelectable: false,
+			},
+		)
+	}
+
+	tui.tableMain.ScrollToBeginning()
+	tui.app.SetFocus(tui.tableMain)
+	tui.app.Sync() // without this, listing images corrupts the screen
+}
+
+// List environment variables that are set by the config, passed through or denied and the rule that decided it
+func (tui *TUI) listEnvironment() {
+	tui.editParam = "Environment"
+
+	entries, err := tui.pbConfig.EnvPassList()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Could not list environment variables: %s", err))
+		tui.pages.SwitchToPage("main text")
+		tui.textMain1.SetText(fmt.Sprintf("Error listing environment variables: %s", err))
+		return
+	}
+
+	tui.renderHeader()
+	tui.pages.SwitchToPage("main table")
+	tui.tableMain.Clear()
+	tui.tableMain.SetTitle("Environment")
+	tui.tableMain.SetSelectable(true, false)
+
+	tui.tableMain.SetCell(0, 1, &tview.TableCell{
+		Text:          fmt.Sprintf("%-6s %-32s %-28s %s", "STATUS", "NAME", "RULE", "SOURCE"),
+		Color:         tcell.ColorWhite,
+		NotSelectable: true,
+	})
+	for idx, e := range entries {
+		color := tcell.ColorYellow
+		if e.Status == cmd.EnvDenied {
+			color = tcell.ColorRed
+		}
+		tui.tableMain.SetCell(
+			idx+1, 1,
+			&tview.TableCell{
+				Text:          fmt.Sprintf("%-6s %-32s %-28s %s", e.Status, e.Name, e.Rule, e.Source),
+				Color:         color,
+				NotSelectable: false,
+			},
+		)
+	}
+
+	tui.tableMain.ScrollToBeginning()
+	tui.app.SetFocus(tui.tableMain)
+	tui.app.Sync() // without this, listing images corrupts the screen
+}
+
+// Re-read the config file with a different profile and update the main menu and advanced form
+func (tui *TUI) switchProfile(profile string) {
+
+	c := cmd.NewPlaybookConfig()
+	c.ConfigFilePath = tui.pbConfig.ConfigFilePath
+	c.TempDirPath = tui.pbConfig.TempDirPath
+	c.Profile = profile
+
+	err := c.LoadConfig(c.ConfigFilePath)
+	if err == nil {
+		err = c.ReadEnvs()
+	}
+	if err == nil {
+		err = tui.applyFlags(c)
+	}
+	if err == nil {
+		err = c.ValidateTuiParams()
+	}
+	if err != nil {
+		errStr := fmt.Sprintf("Error switching to profile %s: %s", profile, err)
+		slog.Error(errStr)
+		tui.textMain1.SetText(errStr)
+		return
+	}
+
+	// keep the same pointer since it is shared with the main menu
+	*tui.pbConfig = *c
+	tui.snapshotConfig()
+	tui.textMain1.Clear()
+
+	tui.setParam("Inventory", c.InventoryFile)
+	tui.setParam("Playbook", c.Playbook)
+	tui.setParam("Limit", c.LimitHost)
+	tui.setParam("Image", parseImageShort(c.Image))
+	tui.setParam("Profile", c.Profile)
+
+	tui.formAdvanced.GetFormItemByLabel("verbose-level").(*tview.InputField).SetText(fmt.Sprintf("%d", c.VerboseLevel))
+	tui.formAdvanced.GetFormItemByLabel("remote-user").(*tview.InputField).SetText(c.RemoteUser)
+	tui.formAdvanced.GetFormItemByLabel("ssh-private-key-file").(*tview.InputField).SetText(c.SshPrivateKeyFile)
+	tui.formAdvanced.GetFormItemByLabel("virtual-env-path").(*tview.InputField).SetText(c.VirtualEnvPath)
+	tui.formAdvanced.GetFormItemByLabel("windows-group").(*tview.InputField).SetText(c.WindowsGroup)
+	tui.formAdvanced.GetFormItemByLabel("playbook-dir").(*tview.InputField).SetText(c.Tui.PlaybookDir)
+	tui.formAdvanced.GetFormItemByLabel("inventory-dir").(*tview.InputField).SetText(c.Tui.InventoryDir)
+	tui.formAdvanced.GetFormItemByLabel("image-filter").(*tview.InputField).SetText(c.Tui.ImageFilter)
+
+	tui.renderFooter(c.ConfigFilePath)
+}
+
+// CLI flags are not part of the config files, so values set by flags are applied again after a reload
+func (tui *TUI) applyFlags(c *cmd.PlaybookConfig) error {
+	node, err := writeConfigNode(tui.toWriteConfig())
+	if err != nil {
+		return err
+	}
+	for key, value := range configLeaves(node) {
+		source := tui.pbConfig.Source(key)
+		if !strings.HasPrefix(source, cmd.SourceFlag) {
+			continue
+		}
+		err = c.SetParam(key, value.Value, source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (tui *TUI) listImages() {
 	tui.editParam = "Images"
 
-	containerCmd, err := cmd.GetContainerRuntime()
+	containerCmd, err := cmd.GetContainerRuntime(tui.pbConfig.Container.Runtime)
 	if err != nil {
 		slog.Error(fmt.Sprintf("%s", err))
 	}
 	// containerCmd = "cat"
 	containerCmdArgs := []string{"images"}
-	rc, outputSlice, err := cmd.RunBufferedCommand(containerCmd, containerCmdArgs, -1, true, "")
+	rc, outputSlice, err := cmd.RunBufferedCommand(containerCmd, containerCmdArgs, nil, -1, true, "")
 
 	if err != nil {
 		output := strings.Join(*outputSlice, "\n") // convert slice of strings to string
@@ -227,8 +587,8 @@ func parseImageShort(image string) string {
 	return image
 }
 
-func inspectImage(image string) *string {
-	containerCmd, err := cmd.GetContainerRuntime()
+func inspectImage(runtime string, image string) *string {
+	containerCmd, err := cmd.GetContainerRuntime(runtime)
 	if err != nil {
 		slog.Error(fmt.Sprintf("%s", err))
 	}
@@ -254,6 +614,10 @@ func inspectFile(file string) *string {
 }
 
 func (tui *TUI) listLimits() {
+	tui.pullImage(tui.showLimits)
+}
+
+func (tui *TUI) showLimits() {
 	tui.editParam = "Limits"
 
 	tui.renderHeader()
@@ -313,6 +677,15 @@ func (tui *TUI) listLimits() {
 	tui.app.Sync() // without this, listing images corrupts the screen
 }
 
+// Clear cached inventory results and re-run ansible-inventory for the selected inventory
+func (tui *TUI) refreshLimits() {
+	err := tui.pbConfig.ClearInventoryCache()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error clearing inventory cache: %s", err))
+	}
+	tui.listLimits()
+}
+
 func (tui *TUI) listPlaybooks() {
 	filter := true
 	// make filtering optional using variable set by keyboard event handler
@@ -333,29 +706,26 @@ func (tui *TUI) listPlaybooks() {
 	fileExt := []string{"yaml", "yml"}
 
 	slog.Debug(fmt.Sprintf("Checking directory for playbook files with yaml extension: %s", tui.pbConfig.Tui.PlaybookDir))
-	if !filter {
-		yamlFiles, err = cmd.ListDir(tui.pbConfig.Tui.PlaybookDir, fileExt)
-	} else if tui.pbConfig.Tui.PlaybookDir == "." {
-		yamlFiles, err = cmd.ListDir(tui.pbConfig.Tui.PlaybookDir, fileExt)
-	} else {
-		yamlFiles, err = cmd.WalkDir(tui.pbConfig.Tui.PlaybookDir, fileExt)
-	}
+	yamlFiles, err = tui.scanner.Scan(tui.pbConfig.Tui.PlaybookDir, cmd.ScanOptions{
+		Exts:    fileExt,
+		Recurse: filter && tui.pbConfig.Tui.PlaybookDir != ".",
+		Include: tui.pbConfig.Tui.Include,
+		Exclude: tui.pbConfig.Tui.Exclude,
+	})
 	if err != nil {
 		slog.Error(fmt.Sprintf("%s", err))
 	}
 
-	sort.Strings(yamlFiles)
+	// index plays (names and hosts) for each playbook to display next to the file name
+	playbooks := make(map[string]cmd.PlaybookInfo)
+	for _, pb := range tui.scanner.IndexPlaybooks(yamlFiles) {
+		playbooks[pb.Path] = pb
+	}
 
 	idx := 0
 	for _, line := range yamlFiles {
-		pbBool := true
-		if filter {
-			pbBool, err = cmd.IsPlaybookFile(line)
-			if err != nil {
-				slog.Error(fmt.Sprintf("Could not check if file is playbook: %s, %s", line, err))
-			}
-		}
-		if pbBool {
+		pb, pbBool := playbooks[line]
+		if pbBool || !filter {
 			tui.tableMain.SetCell(
 				idx, 1,
 				&tview.TableCell{
@@ -364,6 +734,14 @@ func (tui *TUI) listPlaybooks() {
 					NotSelectable: false,
 				},
 			)
+			tui.tableMain.SetCell(
+				idx, 2,
+				&tview.TableCell{
+					Text:          pb.Summary(),
+					Color:         tcell.ColorGreen,
+					NotSelectable: false,
+				},
+			)
 			idx++
 		}
 	}
@@ -408,10 +786,13 @@ func (tui *TUI) listInventoryFiles() {
 	slog.Debug(fmt.Sprintf("Checking directory for inventory files with yaml/yml, ini, txt extensions: %s", tui.pbConfig.Tui.InventoryDir))
 	if !filter {
 		yamlFiles, err = cmd.ListDir(tui.pbConfig.Tui.InventoryDir, []string{})
-	} else if tui.pbConfig.Tui.InventoryDir == "." {
-		yamlFiles, err = cmd.ListDir(tui.pbConfig.Tui.InventoryDir, fileExt)
 	} else {
-		yamlFiles, err = cmd.WalkDir(tui.pbConfig.Tui.InventoryDir, fileExt)
+		yamlFiles, err = tui.scanner.Scan(tui.pbConfig.Tui.InventoryDir, cmd.ScanOptions{
+			Exts:    fileExt,
+			Recurse: tui.pbConfig.Tui.InventoryDir != ".",
+			Include: tui.pbConfig.Tui.Include,
+			Exclude: tui.pbConfig.Tui.Exclude,
+		})
 	}
 	if err != nil {
 		slog.Error(fmt.Sprintf("%s", err))
@@ -419,13 +800,13 @@ func (tui *TUI) listInventoryFiles() {
 
 	sort.Strings(yamlFiles)
 
+	inventories := tui.scanner.DetectInventories(yamlFiles)
+
 	idx := 0
 	for _, line := range yamlFiles {
-		pbBool, err := cmd.IsInventory(line)
-		if err != nil {
-			slog.Error(fmt.Sprintf("Could not check if file is inventory: %s, %s", line, err))
-		}
-		if pbBool {
+		invInfo := inventories[line]
+		// when showing all files, entries that are not recognized as inventory have no label
+		if invInfo.Type != cmd.InventoryTypeNone || !filter {
 			tui.tableMain.SetCell(
 				idx, 1,
 				&tview.TableCell{
@@ -434,6 +815,14 @@ func (tui *TUI) listInventoryFiles() {
 					NotSelectable: false,
 				},
 			)
+			tui.tableMain.SetCell(
+				idx, 2,
+				&tview.TableCell{
+					Text:          invInfo.Label(),
+					Color:         tcell.ColorGreen,
+					NotSelectable: false,
+				},
+			)
 			idx++
 		}
 	}
@@ -473,28 +862,28 @@ func tuiExecuteLint(c *cmd.PlaybookConfig, target string) {
 	err := c.ProcessEnvs()
 	if err != nil {
 		slog.Error(fmt.Sprintf("Exiting due to errors processing inputs: %s", err))
-		os.Exit(1)
+		cmd.Exit(1)
 	}
 
 	// validate inputs in PlaybookConfig struct
 	err = c.ValidateInputs()
 	if err != nil {
 		slog.Error(fmt.Sprintf("Exiting due validation errors: %s", err))
-		os.Exit(1)
+		cmd.Exit(1)
 	}
 
 	// Call lint method in cmd package, which will run ansible-lint in a container or python virtualenv
 	c.Metrics.ExitCode, err = c.RunAnsibleLint(target)
 	if err != nil {
 		slog.Error(fmt.Sprintf("Error running lint: %s", err))
-		os.Exit(1)
+		cmd.Exit(1)
 	}
 
 	// Final exit code is based on the results of above RunAnsibleLint method call
 	if err != nil {
-		os.Exit(c.Metrics.ExitCode)
+		cmd.Exit(c.Metrics.ExitCode)
 	}
-	os.Exit(c.Metrics.ExitCode)
+	cmd.Exit(c.Metrics.ExitCode)
 }
 
 func tuiExecutePlaybook(c *cmd.PlaybookConfig) {
@@ -509,28 +898,76 @@ func tuiExecutePlaybook(c *cmd.PlaybookConfig) {
 	err := c.ProcessEnvs()
 	if err != nil {
 		slog.Error(fmt.Sprintf("Exiting due to errors processing inputs: %s", err))
-		os.Exit(1)
+		cmd.Exit(1)
 	}
 
 	// validate inputs in PlaybookConfig struct
 	err = c.ValidateInputs()
 	if err != nil {
 		slog.Error(fmt.Sprintf("Exiting due validation errors: %s", err))
-		os.Exit(1)
+		cmd.Exit(1)
 	}
 
 	// Using PlaybookConfig struct, determine runtime environment (ansible in path, in Python venv, or container).
 	// If container execution, write struct to file, run ansible-tui inside container to read and execute ansible.
+	start := time.Now()
 	c.Metrics.ExitCode, err = c.RunAnsiblePlaybook()
+	if herr := c.RecordRun(start, c.Metrics.ExitCode, err); herr != nil {
+		slog.Warn(fmt.Sprintf("Unable to record run in history: %s", herr))
+	}
 	if err != nil {
 		slog.Error(fmt.Sprintf("Error running playbook: %s", err))
-		os.Exit(1)
+		cmd.Exit(1)
 	}
 
 	// Final exit code is based on the results of above RunAnsiblePlaybook method call
 	if err != nil {
-		os.Exit(c.Metrics.ExitCode)
+		cmd.Exit(c.Metrics.ExitCode)
 	}
-	os.Exit(c.Metrics.ExitCode)
+	cmd.Exit(c.Metrics.ExitCode)
+
+}
 
+// Pull the container image (see pull-policy) before running next.  The progress of the pull is shown in a modal.
+func (tui *TUI) pullImage(next func()) {
+	if !tui.pbConfig.NeedsImagePull() {
+		next()
+		return
+	}
+
+	textPull := tview.NewTextView().SetScrollable(true)
+	textPull.SetBorder(true).SetTitle(fmt.Sprintf("Pulling image %s", tui.pbConfig.Image))
+	textPull.SetChangedFunc(func() {
+		textPull.ScrollToEnd()
+		tui.app.Draw()
+	})
+
+	// centered modal over the main content
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(textPull, 15, 1, true).
+			AddItem(nil, 0, 1, false), 0, 3, true).
+		AddItem(nil, 0, 1, false)
+	tui.pages.AddPage("pull image", modal, true, true)
+	tui.app.SetFocus(textPull)
+
+	go func() {
+		err := tui.pbConfig.PullImage(textPull)
+		tui.app.QueueUpdateDraw(func() {
+			if err != nil {
+				slog.Error(fmt.Sprintf("Error pulling image: %s", err))
+				fmt.Fprintf(textPull, "\n[red]Error pulling image: %s[white]\n\nPress Esc to close\n", tview.Escape(err.Error()))
+				textPull.SetDynamicColors(true)
+				textPull.SetDoneFunc(func(key tcell.Key) {
+					tui.pages.RemovePage("pull image")
+					tui.app.SetFocus(tui.listNav)
+				})
+				return
+			}
+			tui.pages.RemovePage("pull image")
+			next()
+		})
+	}()
 }
@@ -18,12 +18,18 @@ func (tui *TUI) setupKeyboard() {
 			} else {
 				return event
 			}
+		case 'r':
+			if tui.editParam == "Limits" {
+				tui.refreshLimits()
+			} else {
+				return event
+			}
 		case 'a':
 			if tui.editParam == "Inventory" {
 				tui.editParam = "Inventory-all"
 				tui.listInventoryFiles()
-			} else if tui.editParam == "Playbook" {
-				tui.editParam = "Playbook-all"
+			} else if tui.editParam == "Playbooks" {
+				tui.editParam = "Playbooks-all"
 				tui.listPlaybooks()
 			} else if tui.editParam == "Images" {
 				tui.editParam = "Images-all"
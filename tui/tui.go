@@ -10,6 +10,7 @@ import (
 	"log/slog"
 
 	"github.com/rivo/tview"
+	"gopkg.in/yaml.v3"
 )
 
 // global variables
@@ -23,6 +24,8 @@ var (
 type TUI struct {
 	// Internal structures.
 	pbConfig *cmd.PlaybookConfig
+	scanner  *cmd.Scanner      // caches playbook/inventory file lists between page visits
+	loaded   map[string]string // config values when loaded, to find values edited in the TUI
 
 	// View components.
 	app          *tview.Application
@@ -39,15 +42,19 @@ type TUI struct {
 }
 
 type playbookEnvironmentVariables struct {
-	Pass []string          `json:"pass"`
-	Set  map[string]string `json:"set"`
+	Pass   []string          `json:"pass"`
+	Deny   []string          `yaml:"deny,omitempty" json:"deny,omitempty"`
+	Set    map[string]string `json:"set"`
+	Secret []string          `yaml:"secret,omitempty" json:"secret,omitempty"`
 }
 
 type tuiParams struct {
-	PlaybookDir    string `yaml:"playbook-dir" json:"playbook-dir"`
-	InventoryDir   string `yaml:"inventory-dir" json:"inventory-dir"`
-	ImageFilter    string `yaml:"image-filter" json:"image-filter"`
-	VirtualEnvsDir string `yaml:"virtual-envs-dir" json:"virtual-envs-dir"`
+	PlaybookDir    string   `yaml:"playbook-dir" json:"playbook-dir"`
+	InventoryDir   string   `yaml:"inventory-dir" json:"inventory-dir"`
+	ImageFilter    string   `yaml:"image-filter" json:"image-filter"`
+	VirtualEnvsDir string   `yaml:"virtual-envs-dir" json:"virtual-envs-dir"`
+	Include        []string `yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude        []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
 }
 type writeConfig struct {
 	Playbook             string                       `yaml:"playbook" json:"playbook"`
@@ -56,6 +63,7 @@ type writeConfig struct {
 	Image                string                       `yaml:"image" json:"image"`
 	VerboseLevel         int                          `yaml:"verbose-level" json:"verbose-level"`
 	SshPrivateKeyFile    string                       `yaml:"ssh-private-key-file" json:"ssh-private-key-file"`
+	SshAgent             string                       `yaml:"ssh-agent,omitempty" json:"ssh-agent,omitempty"`
 	RemoteUser           string                       `yaml:"remote-user" json:"remote-user"`
 	ExtraVarsFile        string                       `yaml:"extra-vars-file" json:"extra-vars-file"`
 	AnsibleTags          string                       `yaml:"tags" json:"tags"`
@@ -63,32 +71,48 @@ type writeConfig struct {
 	ExtraArgs            string                       `yaml:"extra-args" json:"extra-args"`
 	WindowsGroup         string                       `yaml:"windows-group" json:"windows-group"`
 	VirtualEnvPath       string                       `yaml:"virtual-env-path" json:"virtual-env-path"`
+	PullPolicy           string                       `yaml:"pull-policy,omitempty" json:"pull-policy,omitempty"`
+	Container            cmd.ContainerConfig          `yaml:"container,omitempty" json:"container,omitempty"`
 	PlaybookTimeout      int                          `yaml:"playbook-timeout" json:"playbook-timeout"`
 	EnvironmentVariables playbookEnvironmentVariables `yaml:"environment-variables"`
+	PathPolicy           cmd.PathPolicy               `yaml:"path-policy,omitempty" json:"path-policy,omitempty"`
 	Tui                  tuiParams                    `yaml:"tui" json:"tui"`
+	Profiles             map[string]yaml.Node         `yaml:"profiles,omitempty" json:"profiles,omitempty"`
 }
 
 func (tui *TUI) toWriteConfig() writeConfig {
+	return newWriteConfig(tui.pbConfig)
+}
+
+func newWriteConfig(c *cmd.PlaybookConfig) writeConfig {
 
 	wc := writeConfig{
-		Playbook:             tui.pbConfig.Playbook,
-		InventoryFile:        tui.pbConfig.InventoryFile,
-		LimitHost:            tui.pbConfig.LimitHost,
-		Image:                tui.pbConfig.Image,
-		VerboseLevel:         tui.pbConfig.VerboseLevel,
-		SshPrivateKeyFile:    tui.pbConfig.SshPrivateKeyFile,
-		RemoteUser:           tui.pbConfig.RemoteUser,
-		ExtraVarsFile:        tui.pbConfig.ExtraVarsFile,
-		AnsibleTags:          tui.pbConfig.AnsibleTags,
-		AnsibleSkipTags:      tui.pbConfig.AnsibleSkipTags,
-		ExtraArgs:            tui.pbConfig.ExtraArgs,
-		WindowsGroup:         tui.pbConfig.WindowsGroup,
-		VirtualEnvPath:       tui.pbConfig.VirtualEnvPath,
-		PlaybookTimeout:      tui.pbConfig.PlaybookTimeout,
-		EnvironmentVariables: playbookEnvironmentVariables(tui.pbConfig.EnvironmentVariables),
-		Tui:                  tuiParams(tui.pbConfig.Tui),
+		Playbook:             c.Playbook,
+		InventoryFile:        c.InventoryFile,
+		LimitHost:            c.LimitHost,
+		Image:                c.Image,
+		VerboseLevel:         c.VerboseLevel,
+		SshPrivateKeyFile:    c.SshPrivateKeyFile,
+		SshAgent:             c.SshAgent,
+		RemoteUser:           c.RemoteUser,
+		ExtraVarsFile:        c.ExtraVarsFile,
+		AnsibleTags:          c.AnsibleTags,
+		AnsibleSkipTags:      c.AnsibleSkipTags,
+		ExtraArgs:            c.ExtraArgs,
+		WindowsGroup:         c.WindowsGroup,
+		VirtualEnvPath:       c.VirtualEnvPath,
+		PullPolicy:           c.PullPolicy,
+		Container:            c.Container,
+		PlaybookTimeout:      c.PlaybookTimeout,
+		EnvironmentVariables: playbookEnvironmentVariables(c.EnvironmentVariables),
+		PathPolicy:           c.PathPolicy,
+		Tui:                  tuiParams(c.Tui),
+		Profiles:             c.Profiles,
 	}
 
+	// secret values are never written to the config file
+	wc.EnvironmentVariables.Set = c.WritableEnvs()
+
 	return wc
 }
 
@@ -114,17 +138,21 @@ func (tui *TUI) mainMenu(c *cmd.PlaybookConfig) *tview.List {
 		AddItem("Limit", c.LimitHost, 'l', func() { tui.listLimits() }).
 		AddItem("Image", c.Image, 'I', func() { tui.listImages() }).
 		AddItem("Advanced", "", 'a', func() { tui.showAdvanced() }).
+		AddItem("Profile", c.Profile, 'P', func() { tui.listProfiles() }).
+		AddItem("Environment", "", 'e', func() { tui.listEnvironment() }).
 		AddItem("Save", "", 's', func() { tui.save() }).
 		AddItem("Lint", "", 'L', func() { tui.lintMenu() }).
 		AddItem("Run", "", 'r', func() {
-			tui.flex.Clear()
-			tui.app.Sync()
-			tui.Stop()
-			tuiExecutePlaybook(c)
+			tui.pullImage(func() {
+				tui.flex.Clear()
+				tui.app.Sync()
+				tui.Stop()
+				tuiExecutePlaybook(c)
+			})
 		}).
 		AddItem("Quit", "", 'q', func() {
 			tui.Stop()
-			os.Exit(0)
+			cmd.Exit(0)
 		})
 
 	return list
@@ -142,6 +170,8 @@ func (tui *TUI) setParam(key string, value string) {
 		tui.listNav.SetItemText(3, key, value)
 	case "Advanced":
 		tui.listNav.SetItemText(4, key, value)
+	case "Profile":
+		tui.listNav.SetItemText(5, key, value)
 	}
 }
 
@@ -154,7 +184,7 @@ func (tui *TUI) handleInspectTableCell(row int, _ int) {
 	case "Images":
 		fields := strings.Fields(cell)
 		imageVal := fmt.Sprintf("%s:%s", fields[0], fields[1])
-		inspect = inspectImage(imageVal)
+		inspect = inspectImage(tui.pbConfig.Container.Runtime, imageVal)
 		tui.textDetail1.SetText(*inspect)
 		tui.textDetail1.ScrollToBeginning()
 		tui.pages.SwitchToPage("detail text")
@@ -230,14 +260,16 @@ func (tui *TUI) handleVerifyTableCell(row int, col int) {
 	case "Inventory":
 		fields := strings.Fields(cell)
 		inventoryVal := fields[0]
-		verifyOutput := verifyInventoryFile(tui.pbConfig, inventoryVal)
-		slog.Debug(*verifyOutput)
-
-		tui.textDetail1.SetText(*verifyOutput)
-		tui.textDetail1.ScrollToBeginning()
-		tui.pages.SwitchToPage("detail text")
-		tui.app.SetFocus(tui.textDetail1)
-		tui.app.Sync()
+		tui.pullImage(func() {
+			verifyOutput := verifyInventoryFile(tui.pbConfig, inventoryVal)
+			slog.Debug(*verifyOutput)
+
+			tui.textDetail1.SetText(*verifyOutput)
+			tui.textDetail1.ScrollToBeginning()
+			tui.pages.SwitchToPage("detail text")
+			tui.app.SetFocus(tui.textDetail1)
+			tui.app.Sync()
+		})
 	}
 
 }
@@ -305,13 +337,24 @@ func (tui *TUI) handleSelectedTableCell(row int, col int) {
 		tui.textMain1.Clear()
 		tui.app.SetFocus(tui.listNav)
 		tui.app.Sync()
+	case "Profiles":
+		profileVal := ""
+		if fields := strings.Fields(cell); row > 0 && len(fields) > 0 {
+			profileVal = fields[0]
+		}
+		tui.switchProfile(profileVal)
+		tui.pages.SwitchToPage("main content")
+		tui.app.SetFocus(tui.listNav)
+		tui.app.Sync()
 	case "Lint":
 		fields := strings.Fields(cell)
 		lintType := fields[0]
-		tui.flex.Clear()
-		tui.app.Sync()
-		tui.Stop()
-		tuiExecuteLint(tui.pbConfig, lintType)
+		tui.pullImage(func() {
+			tui.flex.Clear()
+			tui.app.Sync()
+			tui.Stop()
+			tuiExecuteLint(tui.pbConfig, lintType)
+		})
 		// tui.pages.SwitchToPage("main text")
 		// tui.textMain1.Clear()
 		// tui.app.SetFocus(tui.textMain1)
@@ -325,12 +368,14 @@ func (tui *TUI) handleSelectedTableCell(row int, col int) {
 func NewTUI(c *cmd.PlaybookConfig) (*TUI, error) {
 	t := TUI{}
 	t.pbConfig = c
+	t.scanner = cmd.NewScanner()
+	t.snapshotConfig()
 
 	// Set log file for TUI
 	tuiLogFile := filepath.Join(c.TempDirPath, "tui-last.log")
 	fo, err := os.Create(tuiLogFile)
 	if err != nil {
-		slog.Error("opening file: %v", err)
+		slog.Error(fmt.Sprintf("opening file: %v", err))
 	}
 	// close fo on exit and check for its returned error
 	defer func() {